@@ -1,111 +1,303 @@
 package main
 
 import (
+    "context"
+    "flag"
     "fmt"
-    "path/filepath"
     "os"
-    "strings"
+    "path/filepath"
+    "reflect"
     "regexp"
-    "image"
-    _"image/png"
+    "strings"
     "math"
+    "time"
 )
 
-//const path_stim = "/users/fabianschneider/desktop/programming/go/PNE/data/letters"
-const path_stim = "/users/fabianschneider/desktop/programming/go/PNE/data/numbers"
-
 func main() {
-    // circuit method
-    c := Circuit{}
-    c.Neurogenesis(256, 10)
-    size := len(c.Cluster)
+    dir := flag.String("stimuli", "data/numbers", "directory the stimulus images are in")
+    ext := flag.String("ext", ".png,.jpg,.jpeg,.gif", "comma-separated file extensions of stimuli")
+    label := flag.String("label", `[a-zA-Z]+`, "expression whose matches in a file name make up the stimulus' label")
+    variant := flag.String("variant", `[0-9]+`, "expression whose matches in a file name make up the stimulus' variant")
+    idxImages := flag.String("idx-images", "", "IDX file of images (e.g. MNIST's, gzipped or not) to train on instead of -stimuli")
+    idxLabels := flag.String("idx-labels", "", "IDX file of the labels of -idx-images")
+    labelsFile := flag.String("labels", "", "file naming the outcomes, one label per line (discovered from the stimuli by default)")
+    labelDirs := flag.Bool("label-dirs", false, "label stimuli by the directory they are in instead of by their file name")
+    side := flag.Int("size", 16, "side in pixels images are resized to; the circuit senses size x size pixels")
+    crop := flag.String("crop", "none", "crop images to their \"center\" (-crop-size pixels square) or to the \"box\" around their ink first")
+    cropSize := flag.Int("crop-size", 0, "side in pixels of a -crop=center")
+    invert := flag.Bool("invert", true, "images are dark ink on a light background")
+    normalize := flag.Bool("normalize", false, "stretch the contrast of images to the full range")
+    threshold := flag.Float64("threshold", 0, "binarise images: pixels at least this bright (0..1, after inversion) become ink")
+    shift := flag.Float64("shift", 0, "augment: shift images by up to this many pixels either way")
+    rotate := flag.Float64("rotate", 0, "augment: rotate images by up to this many degrees either way")
+    zoom := flag.Float64("zoom", 0, "augment: scale images by up to this fraction either way")
+    elastic := flag.Float64("elastic", 0, "augment: distort images elastically by up to this many pixels")
+    noise := flag.Float64("noise", 0, "augment: add Gaussian noise with this standard deviation (stimuli range 0..0.2)")
+    seed := flag.Int64("seed", 1, "seed of the augmentation")
+    epochs := flag.Int("epochs", 100, "epochs to train for")
+    circuitPath := flag.String("circuit", "circuit.json", "file to save the trained circuit to (not saved if empty, and the checkpoint is kept)")
+    checkpoint := flag.String("checkpoint", "checkpoint.json", "file to write training checkpoints to (none if empty)")
+    checkpointEpochs := flag.Int("checkpoint-epochs", 5, "write a checkpoint every this many epochs (never if 0)")
+    checkpointMinutes := flag.Float64("checkpoint-minutes", 10, "write a checkpoint at least every this many minutes (never if 0)")
+    resume := flag.Bool("resume", false, "carry on from -checkpoint, which must be of a run with the same dataset, labels and -size")
+    flag.Parse()
+    if *side <= 0 {
+        fmt.Fprintf(os.Stderr, "Invalid -size: %d\n", *side)
+        os.Exit(2)
+    }
+    
+    // outcomes are numbered by a labels file, or else by the labels found
+    var labels *LabelSet
+    if *labelsFile != "" {
+        var err error
+        if labels, err = LoadLabelSet(*labelsFile); err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
+        }
+    }
+    
+    // each presents every stimulus once; IDX datasets are streamed
+    var each func(func(ImgStimulus) error) error
+    if *idxImages != "" {
+        if labels == nil {
+            labels = &LabelSet{MNISTNames}
+        }
+        each = func(f func(ImgStimulus) error) error {
+            r, err := OpenIDX(*idxImages, *idxLabels)
+            if err != nil {
+                return err
+            }
+            defer r.Close()
+            r.In, r.Names = *side * *side, labels.Names
+            return r.Each(f)
+        }
+    } else {
+        loader := DefaultStimulusLoader(*dir)
+        loader.Extensions = strings.Split(*ext, ",")
+        loader.LabelFromDir = *labelDirs
+        p := DefaultPreprocessing()
+        p.Invert, p.Normalize, p.Threshold = *invert, *normalize, *threshold
+        p.Width, p.Height = *side, *side
+        switch *crop {
+        case "none":
+        case "center":
+            p.Crop, p.CropWidth, p.CropHeight = croptype.Center, *cropSize, *cropSize
+        case "box":
+            p.Crop = croptype.BoundingBox
+        default:
+            fmt.Fprintf(os.Stderr, "Invalid -crop: %q\n", *crop)
+            os.Exit(2)
+        }
+        loader.Preprocessing = &p
+        var err error
+        if loader.Label, err = regexp.Compile(*label); err != nil {
+            fmt.Fprintf(os.Stderr, "Invalid -label: %v\n", err)
+            os.Exit(2)
+        }
+        if loader.Variant, err = regexp.Compile(*variant); err != nil {
+            fmt.Fprintf(os.Stderr, "Invalid -variant: %v\n", err)
+            os.Exit(2)
+        }
+        stimuli, err := loader.Load()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
+        }
+        if labels == nil {
+            labels = LabelsOf(stimuli)
+        }
+        each = func(f func(ImgStimulus) error) error {
+            for _, stimulus := range stimuli {
+                if err := f(stimulus); err != nil {
+                    return err
+                }
+            }
+            return nil
+        }
+    }
     
-    //constim := map[string]int{"A": 0, "B": 1, "C": 2, "D": 3, "E": 4, "F": 5}
-    //stimcon := map[int]string{0: "A", 1: "B", 2: "C", 3: "D", 4: "E", 5: "F"}
-    constim := map[string]int{"ZERO": 0, "ONE": 1, "TWO": 2, "THREE": 3, "FOUR": 4, "FIVE": 5, "SIX": 6, "SEVEN": 7, "EIGHT": 8, "NINE": 9}
-    stimcon := map[int]string{0: "ZERO", 1: "ONE", 2: "TWO", 3: "THREE", 4: "FOUR", 5: "FIVE", 6: "SIX", 7: "SEVEN", 8: "EIGHT", 9: "NINE"}
-    stimuli := LoadStimuli()
+    // stimuli are varied anew every time they're presented
+    aug := &Augmentation{Seed: *seed, Shift: *shift, Rotate: *rotate, Zoom: *zoom, Elastic: *elastic, ElasticSigma: 2, Noise: *noise, Max: DefaultPreprocessing().Scale}
+    augment := *shift > 0 || *rotate > 0 || *zoom > 0 || *elastic > 0 || *noise > 0
+    
+    dataset := filepath.Clean(*dir)
+    if *idxImages != "" {
+        dataset = filepath.Clean(*idxImages)
+    }
+    
+    // circuit method
+    c := &Circuit{Labels: labels}
+    c.Neurogenesis(*side * *side, labels.Len())
     
     count := 0
     right := 0
-    error := 0
+    wrong := 0
+    
+    // pick up where the last run left off, if asked to
+    var checkpoints *Checkpointer
+    if *checkpoint != "" {
+        checkpoints = &Checkpointer{Path: *checkpoint, Epochs: *checkpointEpochs, Interval: time.Duration(*checkpointMinutes * float64(time.Minute))}
+    }
+    epoch := 0
+    if *resume {
+        if checkpoints == nil {
+            fmt.Fprintf(os.Stderr, "-resume needs a -checkpoint\n")
+            os.Exit(2)
+        }
+        resumed, state, err := checkpoints.Resume()
+        if err == nil {
+            err = resumable(resumed, state, dataset, *side * *side, labels, *epochs)
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Can't resume from %s: %v\n", *checkpoint, err)
+            os.Exit(1)
+        }
+        c = resumed
+        epoch, count, right, wrong = state.Epoch, state.Trials, state.Right, state.Error
+        aug.Seek(state.Augmented)
+        fmt.Printf("Resuming after trial %d.\n", epoch)
+    }
     
-    for i := 0; i < 100; i++ {
+    for i := epoch; i < *epochs; i++ {
         countThis := 0
         countRight := 0
         countError := 0
-        for _, stimulus := range stimuli {
-            res := c.ExposeTo(stimulus.GreyScale)
+        err := each(func(stimulus ImgStimulus) error {
+            outcome, ok := labels.Outcome(stimulus.Type)
+            if !ok {
+                return fmt.Errorf("%s is labelled %q, which isn't one of the circuit's labels", stimulus.Path, stimulus.Type)
+            }
+            if augment {
+                stimulus = aug.Augment(stimulus)
+            }
+            res, err := c.ExposeToContext(context.Background(), stimulus.GreyScale)
+            if err != nil {
+                return fmt.Errorf("%s: %w", stimulus.Path, err)
+            }
             if len(res) > 0 {
                 count += 1
                 countThis += 1
-                if stimulus.Type == stimcon[res[0].outcome] {
+                if res[0].outcome == outcome {
                     right += 1
                     countRight += 1
                 } else {
-                    error += 1
+                    wrong += 1
                     countError += 1
                 }
-                c.CorrectFor(res, constim[stimulus.Type], stimulus.GreyScale)
+                c.CorrectFor(res, outcome, stimulus.GreyScale)
             }
+            return nil
+        })
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
         }
         fmt.Printf("success_rate_overall=%f after trials=%d. success_rate this trial=%f.\n", (float64(right) / float64(count)), count, (float64(countRight) / float64(countThis)))
+        
+        if checkpoints != nil && checkpoints.Due(i + 1) {
+            if err := checkpoints.Save(c, TrainingState{"", i + 1, count, right, wrong, float64(countRight) / float64(countThis), aug.Drawn(), dataset}); err != nil {
+                fmt.Printf("Couldn't save checkpoint: %v\n", err)
+            }
+        }
     }
     
-    fmt.Printf("Size %d -> %d.\n", size, len(c.Cluster))
+    fmt.Printf("Size %d -> %d.\n", c.Initial, len(c.Cluster))
     obs := float64(right)
-    exp := float64(1) / float64(len(constim)) * float64(count)
+    exp := float64(1) / float64(labels.Len()) * float64(count)
     x2 := ((obs - exp) * (obs - exp)) / exp
     p := chi2p(2, x2)
     fmt.Printf("Stats: X^2=%f, p=%f\n", x2, p)
     
+    if *circuitPath == "" {
+        // nothing saved, so the checkpoint is kept
+    } else if err := saveCircuit(c, *circuitPath); err != nil {
+        fmt.Printf("Couldn't save circuit: %v\n", err)
+    } else if checkpoints != nil {
+        // the run is over and its circuit saved; otherwise the checkpoint is
+        // all that's left of it
+        if err := os.Remove(checkpoints.Path); err != nil && !os.IsNotExist(err) {
+            fmt.Printf("Couldn't remove checkpoint: %v\n", err)
+        }
+    }
+    
     
     /*
     // LSBN method
-    stimuli := LoadStimuli()
-    constim := map[string]int{"ZERO": 0, "ONE": 1, "TWO": 2, "THREE": 3, "FOUR": 4, "FIVE": 5, "SIX": 6, "SEVEN": 7, "EIGHT": 8, "NINE": 9}
     var sensations []float64
-    for _, stimulus := range stimuli {
-        for _, sensation := range stimulus.GreyScale {
-            sensations = append(sensations, sensation)
-        }
-    }
+    var stimuli []ImgStimulus
+    each(func(stimulus ImgStimulus) error {
+        stimuli = append(stimuli, stimulus)
+        sensations = append(sensations, stimulus.GreyScale...)
+        return nil
+    })
     lsbn := LargeScaleBrainNetwork{}
     fmt.Printf("Growing ShapesCircuit...\n")
-    types, _, _ := lsbn.GrowCircuit("shapes", 64, 0.6, 256, sensations, true, 0.9)
+    types, _, _ := lsbn.GrowCircuit("shapes", 64, 0.6, *side * *side, sensations, true, 0.9)
     fmt.Printf("ShapesCircuit grown with types=%d.\n", types)
-    lsbn.Grow("numbers", "shapes", 10)
+    lsbn.Grow("numbers", "shapes", labels.Len())
     fmt.Printf("NumbersCircuit grown.\n")
     
-    count := 0
+    lsbnCount := 0
     succs := 0
-    for i := 0; i < 100; i++ {
+    for i := 0; i < *epochs; i++ {
         total := 0
         success := 0
         
         for _, stimulus := range stimuli {
+            outcome, _ := labels.Outcome(stimulus.Type)
             total += 1
-            count += 1
+            lsbnCount += 1
             stim, res := lsbn.Expose("numbers", stimulus.GreyScale)
             if len(res) > 0 {
-                if res[0].outcome == constim[stimulus.Type] {
+                if res[0].outcome == outcome {
                     success += 1
                     succs += 1
                 }
-                lsbn.Correct("numbers", res, constim[stimulus.Type], stim)
+                lsbn.Correct("numbers", res, outcome, stim)
             }
         }
         
-        fmt.Printf("Trials no.%d done with overall_success_rate=%.4f success_rate=%.4f.\n", i, float64(succs) / float64(count), float64(success) / float64(total))
+        fmt.Printf("Trials no.%d done with overall_success_rate=%.4f success_rate=%.4f.\n", i, float64(succs) / float64(lsbnCount), float64(success) / float64(total))
     }
     
-    obs := float64(succs)
-    exp := float64(1) / float64(len(constim)) * float64(count)
-    x2 := ((obs - exp) * (obs - exp)) / exp
-    p := chi2p(2, x2)
+    obs = float64(succs)
+    exp = float64(1) / float64(labels.Len()) * float64(lsbnCount)
+    x2 = ((obs - exp) * (obs - exp)) / exp
+    p = chi2p(2, x2)
     fmt.Printf("Stats: X^2=%f, p=%f\n", x2, p)*/
 }
 
+// saveCircuit writes c to the file at path.
+func saveCircuit(c *Circuit, path string) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    err = c.Save(f)
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// resumable checks that a checkpoint is of a run like this one: on the same
+// dataset, with the same labels and as many sensory neurons, and not over yet.
+func resumable(c *Circuit, state TrainingState, dataset string, in int, labels *LabelSet, epochs int) error {
+    if state.Dataset != dataset {
+        return fmt.Errorf("it was trained on %q, not %q", state.Dataset, dataset)
+    }
+    if c.In != in {
+        return fmt.Errorf("its circuit senses %d pixels, not %d", c.In, in)
+    }
+    if c.Labels == nil || !reflect.DeepEqual(c.Labels.Names, labels.Names) {
+        return fmt.Errorf("its circuit wasn't trained on the labels %v", labels.Names)
+    }
+    if state.Epoch >= epochs {
+        return fmt.Errorf("its run is already over after %d epochs", state.Epoch)
+    }
+    return nil
+}
+
 func chi2p(dof int, distance float64) float64 {
     return gammaIncQ(.5*float64(dof), .5*distance)
 }
@@ -141,89 +333,3 @@ func simpson38(f ifctn, a, b float64, n int) float64 {
     }
     return h * sum / 8
 }
-
-type LoadStimulus struct {
-    Type string
-    Variant string
-    Path string
-}
-
-type ImgStimulus struct {
-    Type string
-    Variant string
-    Path string
-    GreyScale []float64
-}
-
-type Pixel struct {
-    R, G, B, A int
-}
-
-func LoadStimuli() []ImgStimulus {
-    files := []LoadStimulus{}
-    
-    filepath.Walk(path_stim, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-        if !info.IsDir() {
-            if path[len(path)-4:] == ".png" {
-                s := strings.Split(path, "/")
-                name := s[len(s)-1]
-                name = name[:len(name)-4]
-                re := regexp.MustCompile(`[^a-zA-Z]+`)
-                Type := re.ReplaceAllString(name, "")
-                re2 := regexp.MustCompile(`[^0-9]+`)
-                Variant := re2.ReplaceAllString(name, "")
-                files = append(files, LoadStimulus{Type, Variant, path})
-            }
-        }
-        return nil
-    })
-    
-    images := []ImgStimulus{}
-    
-    for _, stimulus := range files {
-        reader, err := os.Open(stimulus.Path)
-        if err != nil {
-            continue
-        }
-        defer reader.Close()
-        
-        img, _, err2 := image.Decode(reader)
-        if err2 != nil {
-            continue
-        }
-        
-        bounds := img.Bounds()
-        width, height := bounds.Max.X, bounds.Max.Y
-        
-        var Greyscale []float64
-        for y := 0; y < height; y++ {
-            for x := 0; x < width; x++ {
-                RGBA := RGBAToPixel(img.At(x, y).RGBA())
-                GS := GSToGR(PixelToGS(RGBA))
-                Greyscale = append(Greyscale, GS)
-            }
-        }
-        
-        images = append(images, ImgStimulus{stimulus.Type, stimulus.Variant, stimulus.Path, Greyscale})
-    }
-    
-    return images
-}
-
-func RGBAToPixel(r uint32, g uint32, b uint32, a uint32) Pixel {
-    return Pixel{int(r / 257), int(g / 257), int(b / 257), int(a / 257)}
-}
-
-func PixelToGS(pixel Pixel) int {
-    return int(float64(pixel.R) * 0.299 + float64(pixel.G) * 0.587 + float64(pixel.B) * 0.114)
-}
-
-func GSToGR(gs int) float64 {
-    // this makes white the priority. we want black to be the priority.
-    //return (float64(gs) / float64(255)) * 0.2
-    bs := 255 - gs
-    return (float64(bs) / float64(255)) * 0.2
-}
\ No newline at end of file
@@ -0,0 +1,78 @@
+package main
+
+import (
+    "math"
+)
+
+// AdExModel is the adaptive exponential integrate-and-fire model of Brette &
+// Gerstner (2005) in pF, nS, mV, pA and ms. Its spike threshold is taken from
+// the neuron's ThresholdPotential, so it can drift away from Threshold.
+type AdExModel struct {
+    Capacitance float64
+    LeakConductance float64
+    LeakReversal float64
+    Threshold float64
+    SlopeFactor float64
+    AdaptationTimeConstant float64
+    SubthresholdAdaptation float64
+    SpikeAdaptation float64
+    Reset float64
+    Peak float64
+    // scales every input, see gain
+    SynapticGain float64
+}
+
+const adexStep = 0.1
+
+// AdaptiveExponential has the parameters fitted to a cortical pyramidal cell
+// in the original paper, and a gain calibrated like the Izhikevich presets'.
+func AdaptiveExponential() AdExModel {
+    return AdExModel{281, 30, -70.6, -50.4, 2, 144, 4, 80.5, -70.6, 20, 2.5}
+}
+
+func (m AdExModel) Name() string {
+    return "adex"
+}
+
+func (m AdExModel) derivativesFor(n *Neuron) derivatives {
+    vt := n.ThresholdPotential * 100
+    return func(v float64, w float64) (float64, float64) {
+        spike := m.LeakConductance * m.SlopeFactor * math.Exp((v - vt) / m.SlopeFactor)
+        dv := (-m.LeakConductance * (v - m.LeakReversal) + spike - w) / m.Capacitance
+        dw := (m.SubthresholdAdaptation * (v - m.LeakReversal) - w) / m.AdaptationTimeConstant
+        return dv, dw
+    }
+}
+
+func (m AdExModel) Genesis(n *Neuron) {
+    n.ThresholdPotential = m.Threshold / 100
+    m.AssumeRestingPotential(n)
+}
+
+func (m AdExModel) AssumeRestingPotential(n *Neuron) {
+    n.MembranePotential = m.LeakReversal / 100
+    n.Recovery = 0
+    n.InRefractoryPeriod = false
+}
+
+func (m AdExModel) Hyperpolarization(n *Neuron, now float64) {
+    evolve(n, now, adexStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential = m.Reset / 100
+    n.Recovery += m.SpikeAdaptation
+}
+
+func (m AdExModel) Inhibit(n *Neuron, amount float64, now float64) {
+    evolve(n, now, adexStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential -= amount * gain(m.SynapticGain)
+}
+
+func (m AdExModel) Excite(n *Neuron, amount float64, now float64) (bool, float64) {
+    evolve(n, now, adexStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential += amount * gain(m.SynapticGain)
+    return upcoming(n, adexStep, m.Peak, m.derivativesFor(n))
+}
+
+func (m AdExModel) Activate(n *Neuron, now float64) (bool, float64) {
+    m.Hyperpolarization(n, now)
+    return upcoming(n, adexStep, m.Peak, m.derivativesFor(n))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+)
+
+// Augmentation makes random variants of image stimuli, so that a circuit
+// trained on few images doesn't learn exact pixel positions. Every variant is
+// shifted by up to Shift pixels either way, rotated by up to Rotate degrees,
+// scaled by up to Zoom (0.1 is 90% to 110%) about the centre, distorted
+// elastically by up to Elastic pixels, smoothly over about ElasticSigma
+// pixels, and given Gaussian noise with a standard deviation of Noise. Zero
+// leaves a distortion out.
+//
+// Images are Width pixels wide (square if Width is 0) with a background of 0,
+// as StimulusLoader and IDXReader load them. Noisy pixels are kept within
+// 0..Max, which should be the Scale of the Preprocessing the images went
+// through; 0 means the default 0.2.
+// Variants are drawn from Seed, so the same Augmentation gives the same
+// variants in the same order.
+type Augmentation struct {
+    Seed int64
+    Width int
+    Shift float64
+    Rotate float64
+    Zoom float64
+    Elastic float64
+    ElasticSigma float64
+    Noise float64
+    Max float64
+    rng *rand.Rand
+    source *countingSource
+}
+
+func (a *Augmentation) rand() *rand.Rand {
+    if a.rng == nil {
+        a.Seek(0)
+    }
+    return a.rng
+}
+
+// Drawn is how many random numbers the Augmentation has drawn; Seek to it to
+// pick up where it left off, e.g. after resuming from a checkpoint.
+func (a *Augmentation) Drawn() uint64 {
+    if a.source == nil {
+        return 0
+    }
+    return a.source.draws
+}
+
+// Seek puts the Augmentation where it was after drawing draws numbers.
+func (a *Augmentation) Seek(draws uint64) {
+    a.source = newCountingSource(a.Seed, draws)
+    a.rng = rand.New(a.source)
+}
+
+// uniform is a random number between -max and max.
+func (a *Augmentation) uniform(max float64) float64 {
+    if max == 0 {
+        return 0
+    }
+    return (a.rand().Float64() * 2 - 1) * max
+}
+
+// Augment returns a random variant of stimulus.
+func (a *Augmentation) Augment(stimulus ImgStimulus) ImgStimulus {
+    stimulus.GreyScale = a.AugmentPixels(stimulus.GreyScale)
+    return stimulus
+}
+
+// AugmentPixels returns a random variant of an image.
+func (a *Augmentation) AugmentPixels(pixels []float64) []float64 {
+    w := a.Width
+    if w <= 0 {
+        w = int(math.Sqrt(float64(len(pixels))))
+    }
+    if w <= 0 || len(pixels) % w != 0 {
+        return append([]float64(nil), pixels...)
+    }
+    h := len(pixels) / w
+
+    dx, dy := a.uniform(a.Shift), a.uniform(a.Shift)
+    angle := a.uniform(a.Rotate) * math.Pi / 180
+    zoom := 1 + a.uniform(a.Zoom)
+    var fx, fy []float64
+    if a.Elastic > 0 {
+        fx, fy = a.displacement(w, h), a.displacement(w, h)
+    }
+
+    // every pixel of the variant is sampled from where the inverse of the
+    // transformation takes it in the original
+    out := make([]float64, len(pixels))
+    cx, cy := float64(w - 1) / 2, float64(h - 1) / 2
+    sin, cos := math.Sin(-angle), math.Cos(-angle)
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            px, py := float64(x) - cx - dx, float64(y) - cy - dy
+            sx := (px * cos - py * sin) / zoom + cx
+            sy := (px * sin + py * cos) / zoom + cy
+            if fx != nil {
+                sx += fx[y * w + x]
+                sy += fy[y * w + x]
+            }
+            out[y * w + x] = bilinear(pixels, w, h, sx, sy)
+        }
+    }
+
+    if a.Noise > 0 {
+        max := a.Max
+        if max <= 0 {
+            max = DefaultPreprocessing().Scale
+        }
+        for i := range out {
+            out[i] = math.Max(0, math.Min(max, out[i] + a.rand().NormFloat64() * a.Noise))
+        }
+    }
+    return out
+}
+
+// displacement is a random field of displacements of up to Elastic pixels
+// that changes smoothly from pixel to pixel: random noise blurred by a
+// Gaussian of ElasticSigma.
+func (a *Augmentation) displacement(w int, h int) []float64 {
+    field := make([]float64, w * h)
+    for i := range field {
+        field[i] = a.uniform(1)
+    }
+
+    sigma := a.ElasticSigma
+    if sigma <= 0 {
+        sigma = 1
+    }
+    radius := int(math.Ceil(3 * sigma))
+    kernel := make([]float64, 2 * radius + 1)
+    for i := range kernel {
+        d := float64(i - radius)
+        kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+    }
+    // blur along the rows, then along the columns
+    blurred := make([]float64, w * h)
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            sum, weight := 0.0, 0.0
+            for k, g := range kernel {
+                if xx := x + k - radius; xx >= 0 && xx < w {
+                    sum += field[y * w + xx] * g
+                    weight += g
+                }
+            }
+            blurred[y * w + x] = sum / weight
+        }
+    }
+    for x := 0; x < w; x++ {
+        for y := 0; y < h; y++ {
+            sum, weight := 0.0, 0.0
+            for k, g := range kernel {
+                if yy := y + k - radius; yy >= 0 && yy < h {
+                    sum += blurred[yy * w + x] * g
+                    weight += g
+                }
+            }
+            field[y * w + x] = sum / weight
+        }
+    }
+
+    // scale the field so its largest displacement is Elastic
+    largest := 0.0
+    for _, v := range field {
+        largest = math.Max(largest, math.Abs(v))
+    }
+    if largest > 0 {
+        for i := range field {
+            field[i] *= a.Elastic / largest
+        }
+    }
+    return field
+}
+
+// bilinear is the image at (x, y), interpolated bilinearly between pixels.
+// Outside the image is background.
+func bilinear(pixels []float64, w int, h int, x float64, y float64) float64 {
+    x0, y0 := math.Floor(x), math.Floor(y)
+    tx, ty := x - x0, y - y0
+    at := func(px int, py int) float64 {
+        if px < 0 || px >= w || py < 0 || py >= h {
+            return 0
+        }
+        return pixels[py * w + px]
+    }
+    ix, iy := int(x0), int(y0)
+    return at(ix, iy) * (1 - tx) * (1 - ty) + at(ix + 1, iy) * tx * (1 - ty) + at(ix, iy + 1) * (1 - tx) * ty + at(ix + 1, iy + 1) * tx * ty
+}
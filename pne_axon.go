@@ -2,8 +2,6 @@ package main
 
 import (
     "math"
-    "math/rand"
-    "time"
     //"fmt"
 )
 
@@ -17,23 +15,23 @@ func (a *axon) Genesis(neuron *Neuron) {
 }
 
 func (a *axon) GrowTerminals() {
-    rand.Seed(time.Now().UnixNano())
+    r := a.From.circuit.Rand()
     
     if a.From.Type == neurontype.Sensory {
         // connect to deep neurons
         deep := len(a.From.circuit.Cluster) - a.From.circuit.In - a.From.circuit.Out
         per := int(math.Ceil(float64(a.From.circuit.In) / float64(deep)))
         this := int(math.Floor(float64(a.From.Index) / float64(per))) + a.From.circuit.In
-        a.Terminals = append(a.Terminals, &axonTerminal{a, a.From.circuit.Cluster[this].GetVacantDendrite(), true})
+        a.Terminals = append(a.Terminals, a.NewTerminal(a.From.circuit.Cluster[this].GetVacantDendrite(), true))
         
         
         /*for i := a.From.circuit.In; i < len(a.From.circuit.Cluster) - a.From.circuit.Out; i++ {
-            a.Terminals = append(a.Terminals, &axonTerminal{a, a.From.circuit.Cluster[i].GetVacantDendrite(), MakeBool(rand.Float64())})
+            a.Terminals = append(a.Terminals, a.NewTerminal(a.From.circuit.Cluster[i].GetVacantDendrite(), MakeBool(r.Float64())))
         }*/
     } else if a.From.Type == neurontype.Deep {
         // connect to mechanical neurons
         for i := (len(a.From.circuit.Cluster) - a.From.circuit.Out); i < len(a.From.circuit.Cluster); i++ {
-            a.Terminals = append(a.Terminals, &axonTerminal{a, a.From.circuit.Cluster[i].GetVacantDendrite(), MakeBool(rand.Float64())})
+            a.Terminals = append(a.Terminals, a.NewTerminal(a.From.circuit.Cluster[i].GetVacantDendrite(), MakeBool(r.Float64())))
         }
         /*
         // connect to deep neurons (but not themselves, obv)
@@ -55,7 +53,7 @@ func (a *axon) GrowTerminals() {
             if (offset + i) >= (len(a.From.circuit.Cluster) - a.From.circuit.Out) {
                 continue
             }
-            a.Terminals = append(a.Terminals, &axonTerminal{a, a.From.circuit.Cluster[offset + i].GetVacantDendrite(), MakeBool(rand.Float64())})
+            a.Terminals = append(a.Terminals, a.NewTerminal(a.From.circuit.Cluster[offset + i].GetVacantDendrite(), MakeBool(r.Float64())))
         }
         */
     } else {
@@ -92,6 +90,34 @@ func MakeBool(p float64) bool {
     return true
 }
 
+func (a *axon) NewTerminal(to *Dendrite, exc bool) *axonTerminal {
+    at := &axonTerminal{a, to, exc, defaultSynapticWeight, 1, a.From.circuit.Delays.Sample(a.From.circuit.Rand()), 0, a.terminalInnovation(to, exc)}
+    if to != nil {
+        to.PartOf.Afferents = append(to.PartOf.Afferents, at)
+    }
+    return at
+}
+
 func (a *axon) GrowSingleTerminal(to int, exc bool) {
-    a.Terminals = append(a.Terminals, &axonTerminal{a, a.From.circuit.Cluster[to].GetVacantDendrite(), exc})
+    a.Terminals = append(a.Terminals, a.NewTerminal(a.From.circuit.Cluster[to].GetVacantDendrite(), exc))
+}
+
+// RemoveTerminal takes at off the axon and off the neuron it synapses onto.
+func (a *axon) RemoveTerminal(at *axonTerminal) {
+    for i, t := range a.Terminals {
+        if t == at {
+            a.Terminals = append(a.Terminals[:i], a.Terminals[i+1:]...)
+            break
+        }
+    }
+    if at.To == nil {
+        return
+    }
+    afferents := at.To.PartOf.Afferents
+    for i, t := range afferents {
+        if t == at {
+            at.To.PartOf.Afferents = append(afferents[:i], afferents[i+1:]...)
+            break
+        }
+    }
 }
\ No newline at end of file
@@ -1,7 +1,74 @@
 package main
 
+import (
+    "math/rand"
+)
+
+// the postsynaptic potential a freshly grown synapse evokes
+const defaultSynapticWeight = 0.075
+
 type axonTerminal struct {
     From *axon
     To *Dendrite
     SynapseIsExcitatory bool
-}
\ No newline at end of file
+    // Weight is the size of the postsynaptic potential, whatever its sign.
+    Weight float64
+    // Efficacy scales Weight independently of the sign, e.g. for neuromodulation.
+    Efficacy float64
+    // Delay is the conduction delay in simulated ms.
+    Delay float64
+    // Eligibility is how much the synapse helped its neuron fire during the
+    // last exposure, see RewardModulation.
+    Eligibility float64
+    // Innovation is the terminal's historical marking, see Innovations.
+    Innovation int
+}
+
+type DistributionType struct {
+    Constant, Uniform, Normal int
+}
+
+var distributiontype = DistributionType{0, 1, 2}
+
+// DelayDistribution is what conduction delays of newly grown terminals are
+// drawn from: Mean for Constant, Mean ± Spread for Uniform and a normal
+// distribution with standard deviation Spread for Normal. Delays are never
+// negative. The zero value gives every terminal the same delay of synapticDelay.
+type DelayDistribution struct {
+    Type int
+    Mean float64
+    Spread float64
+}
+
+func (d DelayDistribution) Sample(r *rand.Rand) float64 {
+    if d == (DelayDistribution{}) {
+        return synapticDelay
+    }
+    
+    delay := d.Mean
+    switch d.Type {
+    case distributiontype.Uniform:
+        delay += (r.Float64() * 2 - 1) * d.Spread
+    case distributiontype.Normal:
+        delay += r.NormFloat64() * d.Spread
+    }
+    if delay < 0 {
+        delay = 0
+    }
+    return delay
+}
+
+// Strength is the postsynaptic potential a spike across the terminal evokes;
+// whether it excites or inhibits is up to SynapseIsExcitatory.
+func (at *axonTerminal) Strength() float64 {
+    return at.Weight * at.Efficacy
+}
+
+// Adjust strengthens (or, for a negative delta, weakens) the synapse. Weights
+// never drop below zero; flipping the sign is a structural change.
+func (at *axonTerminal) Adjust(delta float64) {
+    at.Weight += delta
+    if at.Weight < 0 {
+        at.Weight = 0
+    }
+}
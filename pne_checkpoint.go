@@ -0,0 +1,119 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "time"
+)
+
+// version of the checkpoint format written by Checkpointer.Save
+const checkpointFormatVersion = 1
+
+var ErrCheckpointFormat = errors.New("pne: not a training checkpoint")
+
+// TrainingState is how far a training run has come.
+type TrainingState struct {
+    // identifier of the LSBN circuit being trained, if any
+    Circuit string
+    // epochs completed
+    Epoch int
+    Trials int
+    Right int
+    Error int
+    // success rate of the last epoch completed
+    SuccessRate float64
+    // random numbers drawn by the Augmentation of the training stimuli, see
+    // Augmentation.Seek
+    Augmented uint64
+    // what the run trains on, e.g. a directory of stimuli, so that a
+    // checkpoint isn't resumed on a different dataset
+    Dataset string
+}
+
+type savedCheckpoint struct {
+    Format string
+    Version int
+    State TrainingState
+    Circuit *savedCircuit
+}
+
+// Checkpointer writes the circuit being trained and the state of the run to
+// Path every Epochs epochs and whenever Interval has passed since the last
+// checkpoint, whichever comes first. Checkpoints replace each other, so Path
+// always holds the latest one. Under the event engine, a run resumed from it
+// carries on exactly as if it had never stopped.
+type Checkpointer struct {
+    Path string
+    Epochs int
+    Interval time.Duration
+    saved time.Time
+}
+
+// Due reports whether a checkpoint should be written now that epoch epochs
+// are completed.
+func (cp *Checkpointer) Due(epoch int) bool {
+    if cp.saved.IsZero() {
+        cp.saved = time.Now()
+    }
+    if cp.Epochs > 0 && epoch % cp.Epochs == 0 {
+        return true
+    }
+    return cp.Interval > 0 && time.Since(cp.saved) >= cp.Interval
+}
+
+// Save writes a checkpoint. The previous one is only replaced once the new one
+// is completely written, so a crash while saving loses nothing.
+func (cp *Checkpointer) Save(circuit *Circuit, state TrainingState) error {
+    c, err := circuit.saved()
+    if err != nil {
+        return err
+    }
+    
+    tmp := cp.Path + ".tmp"
+    f, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    err = json.NewEncoder(f).Encode(&savedCheckpoint{"pne-checkpoint", checkpointFormatVersion, state, c})
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    if err := os.Rename(tmp, cp.Path); err != nil {
+        return err
+    }
+    cp.saved = time.Now()
+    return nil
+}
+
+// Resume reads the latest checkpoint. If there is none yet, the error satisfies
+// errors.Is(err, os.ErrNotExist).
+func (cp *Checkpointer) Resume() (*Circuit, TrainingState, error) {
+    f, err := os.Open(cp.Path)
+    if err != nil {
+        return nil, TrainingState{}, err
+    }
+    defer f.Close()
+    
+    s := &savedCheckpoint{}
+    if err := json.NewDecoder(f).Decode(s); err != nil {
+        return nil, TrainingState{}, fmt.Errorf("%w: %v", ErrCheckpointFormat, err)
+    }
+    if s.Format != "pne-checkpoint" || s.Circuit == nil {
+        return nil, TrainingState{}, ErrCheckpointFormat
+    }
+    if s.Version < 1 || s.Version > checkpointFormatVersion {
+        return nil, TrainingState{}, fmt.Errorf("%w: format version %d is not supported", ErrCheckpointFormat, s.Version)
+    }
+    circuit, err := s.Circuit.circuit()
+    if err != nil {
+        return nil, TrainingState{}, err
+    }
+    cp.saved = time.Now()
+    return circuit, s.State, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// A run resumed from a checkpoint carries on exactly like one that never
+// stopped, random draws (here: conduction delays of grown terminals) and all.
+func TestCheckpointResumeMatchesUninterruptedRun(t *testing.T) {
+    stimuli, labels := numbers(t)
+    stimuli = stimuli[:50]
+    grow := func() *Circuit {
+        c := &Circuit{Delays: DelayDistribution{distributiontype.Uniform, 1, 0.5}, Seed: 7}
+        c.Neurogenesis(256, 10, NeuronParams{-0.70, -0.55, -0.90, 20, 2})
+        return c
+    }
+
+    a := grow()
+    uninterrupted := train(t, a, stimuli, labels, 6)
+
+    cp := &Checkpointer{Path: filepath.Join(t.TempDir(), "checkpoint.json"), Epochs: 3}
+    if _, _, err := cp.Resume(); !errors.Is(err, os.ErrNotExist) {
+        t.Fatalf("resuming without a checkpoint: got %v, want os.ErrNotExist", err)
+    }
+    b := grow()
+    var resumed []int
+    for epoch := 1; epoch <= 3; epoch++ {
+        resumed = append(resumed, train(t, b, stimuli, labels, 1)...)
+        if cp.Due(epoch) {
+            if err := cp.Save(b, TrainingState{Epoch: epoch}); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    b, state, err := cp.Resume()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if state.Epoch != 3 {
+        t.Fatalf("resumed after epoch %d, want 3", state.Epoch)
+    }
+    resumed = append(resumed, train(t, b, stimuli, labels, 6 - state.Epoch)...)
+
+    if !reflect.DeepEqual(uninterrupted, resumed) {
+        t.Fatal("resumed run perceived differently from the uninterrupted one")
+    }
+    ga, _ := a.ToGenome()
+    gb, _ := b.ToGenome()
+    if !reflect.DeepEqual(ga, gb) {
+        t.Fatal("resumed run grew a different circuit")
+    }
+}
+
+// An LSBN circuit isn't resumed from a checkpoint of a circuit grown from other
+// chunks, and its checkpoint is gone once training is done.
+func TestLSBNCheckpointOfOtherChunksIsRejected(t *testing.T) {
+    stimuli, _ := numbers(t)
+    var raw []float64
+    for _, s := range stimuli[:4] {
+        raw = append(raw, s.GreyScale...)
+    }
+    grow := func(chunk int) *LargeScaleBrainNetwork {
+        lsbn := &LargeScaleBrainNetwork{}
+        if _, _, ok := lsbn.GrowCircuit("a", chunk, 0.5, 256, raw, false, 0); !ok {
+            t.Fatalf("can't chunk the stimuli by %d", chunk)
+        }
+        return lsbn
+    }
+
+    cp := &Checkpointer{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+    other := grow(16).Circuits["a"]
+    if err := cp.Save(other.Circuit, TrainingState{Circuit: "a", Epoch: 1, Dataset: other.dataset()}); err != nil {
+        t.Fatal(err)
+    }
+    lsbn := grow(64)
+    lsbn.Checkpoints = cp
+    if err := lsbn.TrainCircuitContext(context.Background(), "a", 0); err == nil {
+        t.Fatal("resumed from a checkpoint of other chunks")
+    }
+
+    own := lsbn.Circuits["a"]
+    if err := cp.Save(own.Circuit, TrainingState{Circuit: "a", Epoch: 1, Dataset: own.dataset()}); err != nil {
+        t.Fatal(err)
+    }
+    if err := lsbn.TrainCircuitContext(context.Background(), "a", 0); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(cp.Path); !os.IsNotExist(err) {
+        t.Fatalf("checkpoint is still there after training: %v", err)
+    }
+}
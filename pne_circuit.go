@@ -1,9 +1,13 @@
 package main
 
 import (
-    "time"
+    "context"
+    "errors"
+    "fmt"
     "math"
-    "runtime"
+    "math/rand"
+    "sync"
+    "time"
 )
 
 type Circuit struct {
@@ -13,8 +17,49 @@ type Circuit struct {
     Results []Percept
     MaxConn int
     Inhibitors int
+    // neurons grown by Neurogenesis; everything after was grown by learning
+    Initial int
+    Innovations *Innovations
+    // markings the circuit uses and how often it has made each change, see
+    // innovate
+    marked map[int]bool
+    repeats map[string]int
+    Params NeuronParams
+    Models map[int]NeuronModel
+    Delays DelayDistribution
+    STDP *STDP
+    Reward *RewardModulation
+    Homeostasis *Homeostasis
+    // if set, every spike of an exposure is recorded here
+    Recorder *Recorder
+    // names of the outcomes, if the circuit was grown for a dataset
+    Labels *LabelSet
+    Seed int64
+    rng *rand.Rand
+    source *countingSource
+    Engine int
+    SpikeBudget int
+    TimeBudget float64
+    scheduler *scheduler
+    active sync.WaitGroup
+    mu sync.Mutex
+    plasticity sync.Mutex
+    halted int32
+    spikes int64
+    stopped error
+    epoch time.Time
+    exposed float64
+    // exposures so far; spikes are stamped with the one they happened in
+    exposures int
+    eligible []*axonTerminal
 }
 
+var (
+    ErrStimulusSize = errors.New("pne: stimulus is bigger than the circuit's inputs")
+    ErrSpikeBudget = errors.New("pne: spike budget exhausted")
+    ErrTimeBudget = errors.New("pne: simulated time budget exhausted")
+)
+
 type Percept struct {
     outcome int
 }
@@ -25,10 +70,14 @@ type RankedResult struct {
     confidence float64
 }
 
-func (circuit *Circuit) Neurogenesis(in int, out int) {
+func (circuit *Circuit) Neurogenesis(in int, out int, params ... NeuronParams) {
     // setup counts
     circuit.In = in
     circuit.Out = out
+    circuit.Params = DefaultNeuronParams()
+    if len(params) > 0 {
+        circuit.Params = params[0]
+    }
     n := int(math.Ceil((float64(circuit.In) - float64(circuit.Out)) / float64(2))) + circuit.Out
     if n > circuit.In {
         n = circuit.In
@@ -51,38 +100,260 @@ func (circuit *Circuit) Neurogenesis(in int, out int) {
         circuit.GrowNeuron(t)
     }
     
+    circuit.Initial = len(circuit.Cluster)
+    
     // grow axon terminals
     for i := 0; i < len(circuit.Cluster); i++ {
         circuit.Cluster[i].Axon.GrowTerminals()
     }
 }
 
-func (circuit *Circuit) GrowNeuron(t int) {
+// Clone is a deep copy of the circuit, including the state of every membrane.
+// The copy draws from a fresh source of randomness seeded with Seed.
+func (circuit *Circuit) Clone() *Circuit {
+    c := &Circuit{
+        In: circuit.In,
+        Out: circuit.Out,
+        MaxConn: circuit.MaxConn,
+        Inhibitors: circuit.Inhibitors,
+        Initial: circuit.Initial,
+        Innovations: circuit.Innovations,
+        Labels: circuit.Labels,
+        Params: circuit.Params,
+        Delays: circuit.Delays,
+        Seed: circuit.Seed,
+        Engine: circuit.Engine,
+        SpikeBudget: circuit.SpikeBudget,
+        TimeBudget: circuit.TimeBudget,
+    }
+    if circuit.Models != nil {
+        c.Models = make(map[int]NeuronModel, len(circuit.Models))
+        for t, m := range circuit.Models {
+            c.Models[t] = m
+        }
+    }
+    if circuit.STDP != nil {
+        p := *circuit.STDP
+        c.STDP = &p
+    }
+    if circuit.Reward != nil {
+        p := *circuit.Reward
+        c.Reward = &p
+    }
+    if circuit.Homeostasis != nil {
+        p := *circuit.Homeostasis
+        c.Homeostasis = &p
+    }
+    c.Scheduler().Now = circuit.Scheduler().Now
+    
+    for _, n := range circuit.Cluster {
+        m := &Neuron{
+            circuit: c,
+            Index: n.Index,
+            Type: n.Type,
+            Innovation: n.Innovation,
+            Model: n.Model,
+            MembranePotential: n.MembranePotential,
+            ThresholdPotential: n.ThresholdPotential,
+            InRefractoryPeriod: n.InRefractoryPeriod,
+            RefractoryUntil: n.RefractoryUntil,
+            LastUpdate: n.LastUpdate,
+            Recovery: n.Recovery,
+            LastSpike: n.LastSpike,
+            HasSpiked: n.HasSpiked,
+            FiringRate: n.FiringRate,
+        }
+        m.Axon = &axon{}
+        m.Axon.Genesis(m)
+        for range n.Dendrites {
+            m.Dendrites = append(m.Dendrites, Dendrite{nil, m})
+        }
+        c.Cluster = append(c.Cluster, m)
+    }
+    
+    for i, n := range circuit.Cluster {
+        for d, dendrite := range n.Dendrites {
+            if dendrite.ReceptiveTo != nil {
+                c.Cluster[i].Dendrites[d].ReceptiveTo = c.Cluster[dendrite.ReceptiveTo.From.Index].Axon
+            }
+        }
+        a := c.Cluster[i].Axon
+        for _, at := range n.Axon.Terminals {
+            var to *Dendrite
+            if at.To != nil {
+                to = &c.Cluster[at.To.PartOf.Index].Dendrites[at.To.Position()]
+            }
+            clone := &axonTerminal{a, to, at.SynapseIsExcitatory, at.Weight, at.Efficacy, at.Delay, 0, at.Innovation}
+            a.Terminals = append(a.Terminals, clone)
+            if to != nil {
+                to.PartOf.Afferents = append(to.PartOf.Afferents, clone)
+            }
+        }
+    }
+    
+    return c
+}
+
+// Rest brings every neuron back to its resting potential and forgets past
+// spikes, so that exposures no longer depend on what came before.
+func (circuit *Circuit) Rest() {
+    now := circuit.Now()
+    for _, n := range circuit.Cluster {
+        n.Model.AssumeRestingPotential(n)
+        n.LastUpdate = now
+        n.HasSpiked = false
+        n.spikes = 0
+    }
+    circuit.ClearEligibility()
+}
+
+// Prune removes n and every terminal to or from it. Only neurons grown after
+// Neurogenesis can go, as the mechanical neurons are found by their position.
+func (circuit *Circuit) Prune(n *Neuron) bool {
+    if n.Index < circuit.Initial || n.Index >= len(circuit.Cluster) || circuit.Cluster[n.Index] != n {
+        return false
+    }
+    
+    for len(n.Afferents) > 0 {
+        at := n.Afferents[0]
+        at.From.RemoveTerminal(at)
+    }
+    for len(n.Axon.Terminals) > 0 {
+        n.Axon.RemoveTerminal(n.Axon.Terminals[0])
+    }
+    circuit.Cluster = append(circuit.Cluster[:n.Index], circuit.Cluster[n.Index+1:]...)
+    for i := n.Index; i < len(circuit.Cluster); i++ {
+        circuit.Cluster[i].Index = i
+    }
+    return true
+}
+
+// Rand is the circuit's own source of randomness, seeded with Seed, so that
+// growing the same circuit twice gives the same result.
+func (circuit *Circuit) Rand() *rand.Rand {
+    if circuit.rng == nil {
+        circuit.source = newCountingSource(circuit.Seed, 0)
+        circuit.rng = rand.New(circuit.source)
+    }
+    return circuit.rng
+}
+
+// countingSource is a rand.Source that keeps count of the numbers drawn, as
+// the state of math/rand can't be saved: seeding a new one and drawing as
+// many numbers again brings it to the same state.
+type countingSource struct {
+    src rand.Source64
+    seed int64
+    draws uint64
+}
+
+func newCountingSource(seed int64, draws uint64) *countingSource {
+    s := &countingSource{rand.NewSource(seed).(rand.Source64), seed, 0}
+    for s.draws < draws {
+        s.Uint64()
+    }
+    return s
+}
+
+func (s *countingSource) Int63() int64 {
+    s.draws += 1
+    return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+    s.draws += 1
+    return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+    s.src.Seed(seed)
+    s.seed, s.draws = seed, 0
+}
+
+// GrowNeuron grows a neuron of type t. If it is grown to sit between two
+// neurons, passing their indexes makes its innovation match the same growth
+// in other circuits.
+func (circuit *Circuit) GrowNeuron(t int, between ... int) {
     circuit.Cluster = append(circuit.Cluster, &Neuron{})
     circuit.Cluster[len(circuit.Cluster)-1].Genesis(len(circuit.Cluster)-1, t, circuit)
+    circuit.Cluster[len(circuit.Cluster)-1].Innovation = circuit.neuronInnovation(len(circuit.Cluster)-1, between)
 }
 
 func (circuit *Circuit) ExposeTo(stimulus []float64) []RankedResult {
+    ranked, _ := circuit.ExposeToContext(context.Background(), stimulus)
+    return ranked
+}
+
+// ExposeToContext is ExposeTo, but propagation stops as soon as ctx is done or
+// the circuit's SpikeBudget or TimeBudget (simulated ms) runs out. Whatever
+// percepts were collected up to that point are still ranked and returned.
+func (circuit *Circuit) ExposeToContext(ctx context.Context, stimulus []float64) ([]RankedResult, error) {
     defer func() {
         circuit.Results = nil
     }()
     
     // make sure stimulus isn't bigger than inputs
     if len(stimulus) > circuit.In {
-        return nil
+        return nil, ErrStimulusSize
     }
     
-    for index, stim := range stimulus {
-        go circuit.Cluster[index].Excite(stim)
+    circuit.halted = 0
+    circuit.spikes = 0
+    circuit.stopped = nil
+    if circuit.epoch.IsZero() {
+        circuit.epoch = time.Now()
+    }
+    circuit.exposed = circuit.Now()
+    circuit.exposures += 1
+    circuit.ClearEligibility()
+    if circuit.Recorder != nil {
+        circuit.Recorder.expose()
     }
     
-    for {
-        time.Sleep(100 * time.Millisecond)
-        if runtime.NumGoroutine() == 1 {
-            break
+    if circuit.Engine == enginetype.Goroutine {
+        for index, stim := range stimulus {
+            n, in := circuit.Cluster[index], stim
+            circuit.spawn(func() { n.Excite(in) })
+        }
+        
+        settled := make(chan struct{})
+        go func() {
+            circuit.active.Wait()
+            close(settled)
+        }()
+        select {
+        case <-settled:
+        case <-ctx.Done():
+            circuit.Halt(ctx.Err())
+            <-settled
+        }
+    } else {
+        s := circuit.Scheduler()
+        for index, stim := range stimulus {
+            s.Schedule(s.Now, circuit.Cluster[index], eventtype.Excite, stim)
         }
+        circuit.Run(ctx)
     }
     
+    circuit.Adapt()
+    
+    var err error
+    if circuit.stopped != nil {
+        err = fmt.Errorf("pne: exposure stopped after %d spikes: %w", circuit.spikes, circuit.stopped)
+    }
+    
+    return circuit.Rank(), err
+}
+
+// Perceive records a percept; mechanical neurons may call it concurrently.
+func (circuit *Circuit) Perceive(p Percept) {
+    circuit.mu.Lock()
+    circuit.Results = append(circuit.Results, p)
+    circuit.mu.Unlock()
+}
+
+// Rank orders the percepts collected so far by how often each outcome fired.
+func (circuit *Circuit) Rank() []RankedResult {
     mechano := 0
     count := make(map[int]int)
     for _, res := range circuit.Results {
@@ -120,7 +391,9 @@ func (c *Circuit) CorrectFor(r []RankedResult, v int, stimulus []float64) {
         for in, stim := range stimulus {
             this := int(math.Floor(float64(in) / float64(per))) + c.In
             if c.Cluster[in].MembranePotential + stim > c.Cluster[in].ThresholdPotential {
-                deepPotentials[this] += 0.075
+                if at := c.Cluster[in].Axon.HasTerminalTo(c.Cluster[this]); at != nil {
+                    deepPotentials[this] += at.Strength()
+                }
             }
         }
         
@@ -132,7 +405,7 @@ func (c *Circuit) CorrectFor(r []RankedResult, v int, stimulus []float64) {
                 }
                 
                 if (*at).SynapseIsExcitatory {
-                    (*c).GrowNeuron(neurontype.Deep)
+                    (*c).GrowNeuron(neurontype.Deep, index, realOut)
                     (*c).Cluster[len((*c).Cluster)-1].Axon.GrowSingleTerminal(realOut, true)
                     if (*c).Inhibitors < deep * ((*c).Out-1) {
                         (*c).Cluster[index].Axon.GrowSingleTerminal(designatedOut, false)
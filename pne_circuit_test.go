@@ -0,0 +1,56 @@
+package main
+
+import (
+    "testing"
+)
+
+// numbers loads the handwritten digits in data/numbers and their labels.
+func numbers(t *testing.T) ([]ImgStimulus, *LabelSet) {
+    t.Helper()
+    stimuli, err := DefaultStimulusLoader("data/numbers").Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return stimuli, LabelsOf(stimuli)
+}
+
+// train exposes c to every stimulus epochs times, correcting it for each, and
+// returns the winning outcome of every exposure (-1 if nothing was perceived).
+func train(t *testing.T, c *Circuit, stimuli []ImgStimulus, labels *LabelSet, epochs int) []int {
+    t.Helper()
+    var winners []int
+    for e := 0; e < epochs; e++ {
+        for _, stimulus := range stimuli {
+            outcome, ok := labels.Outcome(stimulus.Type)
+            if !ok {
+                t.Fatalf("%s has no outcome", stimulus.Type)
+            }
+            res := c.ExposeTo(stimulus.GreyScale)
+            if len(res) == 0 {
+                winners = append(winners, -1)
+                continue
+            }
+            winners = append(winners, res[0].outcome)
+            c.CorrectFor(res, outcome, stimulus.GreyScale)
+        }
+    }
+    return winners
+}
+
+// Run with -race: the goroutine engine excites, activates and perceives from
+// many goroutines at once.
+func TestGoroutineEngineIsRaceFree(t *testing.T) {
+    stimuli, labels := numbers(t)
+    c := &Circuit{Engine: enginetype.Goroutine}
+    c.Neurogenesis(256, 10)
+
+    perceived := 0
+    for _, w := range train(t, c, stimuli[:40], labels, 3) {
+        if w >= 0 {
+            perceived += 1
+        }
+    }
+    if perceived == 0 {
+        t.Fatal("no stimulus was perceived")
+    }
+}
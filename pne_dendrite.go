@@ -3,4 +3,14 @@ package main
 type Dendrite struct {
     ReceptiveTo *axon
     PartOf *Neuron
+}
+
+// Position is the index of d among its neuron's dendrites.
+func (d *Dendrite) Position() int {
+    for i := range d.PartOf.Dendrites {
+        if &d.PartOf.Dendrites[i] == d {
+            return i
+        }
+    }
+    return -1
 }
\ No newline at end of file
@@ -0,0 +1,430 @@
+package main
+
+import (
+    "math/rand"
+    "runtime"
+    "sort"
+    "sync"
+)
+
+type Stimulus struct {
+    Sensation []float64
+    Correct int
+}
+
+// Mutations are the chances of each mutation happening to an offspring. The
+// threshold and weight perturbations are applied to every neuron or terminal
+// with their chance, by a normal amount with the given spread.
+type Mutations struct {
+    AddNeuron float64
+    RemoveNeuron float64
+    AddTerminal float64
+    RemoveTerminal float64
+    FlipSign float64
+    PerturbThreshold float64
+    ThresholdSpread float64
+    PerturbWeight float64
+    WeightSpread float64
+}
+
+func DefaultMutations() Mutations {
+    return Mutations{0.3, 0.2, 0.5, 0.3, 0.1, 0.05, 0.01, 0.1, 0.01}
+}
+
+type GenerationReport struct {
+    Generation int
+    Best float64
+    Mean float64
+    Species int
+}
+
+// Species is a group of circuits within compatibility distance of its
+// representative. Members index the population last scored.
+type Species struct {
+    ID int
+    Representative *Circuit
+    Members []int
+}
+
+// Evolver evolves a population of circuits: every generation each circuit is
+// scored on Stimuli (in parallel), the Elites best survive as they are and the
+// rest of the next generation are mutated offspring of tournament winners.
+//
+// With Compatibility set, evolution is NEAT-like instead: circuits are grouped
+// into species, share their fitness within their species, and offspring are
+// bred within a species, from two parents by crossover at CrossoverRate.
+type Evolver struct {
+    Population []*Circuit
+    Scores []float64
+    Stimuli []Stimulus
+    Mutations Mutations
+    Elites int
+    TournamentSize int
+    // goroutines scoring circuits; 0 means one per CPU
+    Workers int
+    Fitness func(c *Circuit, stimuli []Stimulus) float64
+    Seed int64
+    Generation int
+    // best circuit of the last generation scored
+    Champion *Circuit
+    Compatibility *Compatibility
+    CrossoverRate float64
+    Species []*Species
+    species int
+    rng *rand.Rand
+}
+
+// Accuracy is the share of stimuli whose correct outcome ranks first.
+func Accuracy(c *Circuit, stimuli []Stimulus) float64 {
+    if len(stimuli) == 0 {
+        return 0
+    }
+    correct := 0
+    for _, stimulus := range stimuli {
+        res := c.ExposeTo(stimulus.Sensation)
+        if len(res) > 0 && res[0].outcome == stimulus.Correct {
+            correct += 1
+        }
+    }
+    return float64(correct) / float64(len(stimuli))
+}
+
+// Genesis fills the population with size circuits descending from ancestor:
+// one exact copy and size-1 mutated ones. The ancestor itself is left as is.
+func (e *Evolver) Genesis(ancestor *Circuit, size int, stimuli []Stimulus) {
+    e.rng = rand.New(rand.NewSource(e.Seed))
+    e.Stimuli = stimuli
+    e.Generation = 0
+    if e.Mutations == (Mutations{}) {
+        e.Mutations = DefaultMutations()
+    }
+    if e.Elites == 0 {
+        e.Elites = 1
+    }
+    if e.TournamentSize == 0 {
+        e.TournamentSize = 3
+    }
+    if e.Fitness == nil {
+        e.Fitness = Accuracy
+    }
+    if e.CrossoverRate == 0 {
+        e.CrossoverRate = 0.75
+    }
+    e.Species = nil
+    
+    // the whole population shares one record of innovations
+    innovations := ancestor.Innovations
+    if innovations == nil {
+        innovations = &Innovations{}
+    }
+    
+    e.Population = nil
+    for i := 0; i < size; i++ {
+        c := ancestor.Clone()
+        c.Seed = e.rng.Int63()
+        c.Innovations = innovations
+        if i > 0 {
+            e.Mutate(c)
+        }
+        e.Population = append(e.Population, c)
+    }
+}
+
+// Evaluate scores every circuit from rest, spread over Workers goroutines.
+func (e *Evolver) Evaluate() {
+    workers := e.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    
+    e.Scores = make([]float64, len(e.Population))
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range jobs {
+                e.Population[i].Rest()
+                e.Scores[i] = e.Fitness(e.Population[i], e.Stimuli)
+            }
+        }()
+    }
+    for i := range e.Population {
+        jobs <- i
+    }
+    close(jobs)
+    wg.Wait()
+}
+
+func (e *Evolver) tournament() *Circuit {
+    best := -1
+    for i := 0; i < e.TournamentSize; i++ {
+        c := e.rng.Intn(len(e.Population))
+        if best < 0 || e.Scores[c] > e.Scores[best] {
+            best = c
+        }
+    }
+    return e.Population[best]
+}
+
+// Step scores the current generation and breeds the next one.
+func (e *Evolver) Step() GenerationReport {
+    e.Evaluate()
+    
+    order := make([]int, len(e.Population))
+    total := float64(0)
+    for i := range order {
+        order[i] = i
+        total += e.Scores[i]
+    }
+    sort.SliceStable(order, func(a, b int) bool {
+        return e.Scores[order[a]] > e.Scores[order[b]]
+    })
+    report := GenerationReport{e.Generation, e.Scores[order[0]], total / float64(len(order)), 0}
+    e.Champion = e.Population[order[0]]
+    
+    var next []*Circuit
+    if e.Compatibility != nil {
+        e.Speciate()
+        report.Species = len(e.Species)
+        next = e.breedSpecies()
+    } else {
+        for i := 0; i < e.Elites && i < len(order); i++ {
+            next = append(next, e.Population[order[i]])
+        }
+        for len(next) < len(e.Population) {
+            child := e.tournament().Clone()
+            child.Seed = e.rng.Int63()
+            e.Mutate(child)
+            next = append(next, child)
+        }
+    }
+    
+    e.Population = next
+    e.Generation += 1
+    return report
+}
+
+// Speciate sorts the scored population into species. Species keep their
+// representative from the previous generation; circuits that fit none found
+// a new species, and species left without members die out.
+func (e *Evolver) Speciate() {
+    for _, sp := range e.Species {
+        sp.Members = nil
+    }
+    for i, c := range e.Population {
+        var home *Species
+        for _, sp := range e.Species {
+            if c.Distance(sp.Representative, *e.Compatibility) < e.Compatibility.Threshold {
+                home = sp
+                break
+            }
+        }
+        if home == nil {
+            e.species += 1
+            home = &Species{e.species, c, nil}
+            e.Species = append(e.Species, home)
+        }
+        home.Members = append(home.Members, i)
+    }
+    
+    var alive []*Species
+    for _, sp := range e.Species {
+        if len(sp.Members) > 0 {
+            // the next generation is compared against a member of this one
+            sp.Representative = e.Population[sp.Members[e.rng.Intn(len(sp.Members))]]
+            alive = append(alive, sp)
+        }
+    }
+    e.Species = alive
+}
+
+// breedSpecies gives every species a share of the next generation in
+// proportion to its members' fitness divided by the species' size, and fills
+// it with the species' champion and offspring of its tournament winners.
+func (e *Evolver) breedSpecies() []*Circuit {
+    shared := make([]float64, len(e.Species))
+    total := float64(0)
+    for i, sp := range e.Species {
+        for _, m := range sp.Members {
+            shared[i] += e.Scores[m] / float64(len(sp.Members))
+        }
+        total += shared[i]
+    }
+    
+    size := len(e.Population)
+    shares := make([]int, len(e.Species))
+    given := 0
+    for i := range e.Species {
+        if total > 0 {
+            shares[i] = int(shared[i] / total * float64(size))
+        } else {
+            shares[i] = size / len(e.Species)
+        }
+        given += shares[i]
+    }
+    // whatever rounding left over goes to the species of the champion
+    for i, sp := range e.Species {
+        for _, m := range sp.Members {
+            if e.Population[m] == e.Champion {
+                shares[i] += size - given
+            }
+        }
+    }
+    
+    var next []*Circuit
+    for i, sp := range e.Species {
+        if shares[i] == 0 {
+            continue
+        }
+        members := append([]int(nil), sp.Members...)
+        sort.SliceStable(members, func(a, b int) bool {
+            return e.Scores[members[a]] > e.Scores[members[b]]
+        })
+        next = append(next, e.Population[members[0]])
+        for n := 1; n < shares[i]; n++ {
+            a, b := e.tournamentIn(members), e.tournamentIn(members)
+            var child *Circuit
+            if len(members) > 1 && e.rng.Float64() < e.CrossoverRate {
+                if e.Scores[b] > e.Scores[a] {
+                    a, b = b, a
+                }
+                child = Crossover(e.Population[a], e.Population[b], e.rng.Int63())
+            } else {
+                child = e.Population[a].Clone()
+                child.Seed = e.rng.Int63()
+            }
+            e.Mutate(child)
+            next = append(next, child)
+        }
+    }
+    return next
+}
+
+func (e *Evolver) tournamentIn(members []int) int {
+    best := -1
+    for i := 0; i < e.TournamentSize; i++ {
+        c := members[e.rng.Intn(len(members))]
+        if best < 0 || e.Scores[c] > e.Scores[best] {
+            best = c
+        }
+    }
+    return best
+}
+
+func (e *Evolver) Evolve(generations int) []GenerationReport {
+    var reports []GenerationReport
+    for i := 0; i < generations; i++ {
+        reports = append(reports, e.Step())
+    }
+    return reports
+}
+
+func (e *Evolver) Mutate(c *Circuit) {
+    r := c.Rand()
+    m := e.Mutations
+    
+    if r.Float64() < m.AddNeuron {
+        c.MutateAddNeuron()
+    }
+    if r.Float64() < m.RemoveNeuron {
+        c.MutateRemoveNeuron()
+    }
+    if r.Float64() < m.AddTerminal {
+        c.MutateAddTerminal()
+    }
+    if r.Float64() < m.RemoveTerminal {
+        c.MutateRemoveTerminal()
+    }
+    if r.Float64() < m.FlipSign {
+        c.MutateFlipSign()
+    }
+    
+    for _, n := range c.Cluster {
+        if n.Type != neurontype.Sensory && r.Float64() < m.PerturbThreshold {
+            n.ThresholdPotential += r.NormFloat64() * m.ThresholdSpread
+        }
+        for _, at := range n.Axon.Terminals {
+            if r.Float64() < m.PerturbWeight {
+                at.Adjust(r.NormFloat64() * m.WeightSpread)
+            }
+        }
+    }
+}
+
+// layer orders neurons so that terminals only ever run forward and activity
+// can't circle: sensory, deep grown by Neurogenesis, deep grown later,
+// mechanical.
+func (circuit *Circuit) layer(n *Neuron) int {
+    if n.Type == neurontype.Sensory {
+        return 0
+    } else if n.Type == neurontype.Mechanical {
+        return 3
+    } else if n.Index < circuit.Initial {
+        return 1
+    }
+    return 2
+}
+
+// MutateAddNeuron grows a deep neuron between a random sensory or deep neuron
+// and a random mechanical one, like CorrectFor does.
+func (circuit *Circuit) MutateAddNeuron() {
+    r := circuit.Rand()
+    from := r.Intn(circuit.Initial - circuit.Out)
+    to := circuit.MechanicalFor(r.Intn(circuit.Out)).Index
+    circuit.GrowNeuron(neurontype.Deep, from, to)
+    circuit.Cluster[len(circuit.Cluster)-1].Axon.GrowSingleTerminal(to, true)
+    circuit.Cluster[from].Axon.GrowSingleTerminal(len(circuit.Cluster)-1, true)
+}
+
+func (circuit *Circuit) MutateRemoveNeuron() {
+    if len(circuit.Cluster) <= circuit.Initial {
+        return
+    }
+    r := circuit.Rand()
+    circuit.Prune(circuit.Cluster[circuit.Initial + r.Intn(len(circuit.Cluster) - circuit.Initial)])
+}
+
+// MutateAddTerminal connects a random neuron to a random one further
+// downstream that it isn't connected to yet.
+func (circuit *Circuit) MutateAddTerminal() {
+    r := circuit.Rand()
+    from := circuit.Cluster[r.Intn(len(circuit.Cluster))]
+    var targets []*Neuron
+    for _, n := range circuit.Cluster {
+        if circuit.layer(n) > circuit.layer(from) && from.Axon.HasTerminalTo(n) == nil {
+            targets = append(targets, n)
+        }
+    }
+    if len(targets) == 0 {
+        return
+    }
+    from.Axon.GrowSingleTerminal(targets[r.Intn(len(targets))].Index, true)
+}
+
+func (circuit *Circuit) terminals() []*axonTerminal {
+    var terminals []*axonTerminal
+    for _, n := range circuit.Cluster {
+        terminals = append(terminals, n.Axon.Terminals...)
+    }
+    return terminals
+}
+
+func (circuit *Circuit) MutateRemoveTerminal() {
+    terminals := circuit.terminals()
+    if len(terminals) == 0 {
+        return
+    }
+    at := terminals[circuit.Rand().Intn(len(terminals))]
+    at.From.RemoveTerminal(at)
+}
+
+func (circuit *Circuit) MutateFlipSign() {
+    terminals := circuit.terminals()
+    if len(terminals) == 0 {
+        return
+    }
+    at := terminals[circuit.Rand().Intn(len(terminals))]
+    at.SynapseIsExcitatory = !at.SynapseIsExcitatory
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+    "bufio"
+    "encoding/xml"
+    "fmt"
+    "io"
+)
+
+// colours of the neuron types and of the synapses in exported graphs
+var exportColours = map[int][3]int{
+    neurontype.Sensory: {78, 121, 167},
+    neurontype.Deep: {242, 142, 43},
+    neurontype.Mechanical: {89, 161, 79},
+}
+
+var (
+    excitatoryColour = [3]int{40, 40, 40}
+    inhibitoryColour = [3]int{214, 39, 40}
+)
+
+func hexColour(c [3]int) string {
+    return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
+
+func typeName(t int) string {
+    switch t {
+    case neurontype.Sensory:
+        return "sensory"
+    case neurontype.Deep:
+        return "deep"
+    case neurontype.Mechanical:
+        return "mechanical"
+    }
+    return "undetermined"
+}
+
+// WriteDOT writes the circuit as a Graphviz digraph. Neurons are coloured by
+// type, with sensory neurons on the left and mechanical ones on the right;
+// neurons grown after Neurogenesis have a double outline. Inhibitory terminals
+// are red and end in a bar, and lines are as thick as the synapse is strong.
+func (circuit *Circuit) WriteDOT(w io.Writer) error {
+    b := bufio.NewWriter(w)
+    
+    fmt.Fprintf(b, "digraph circuit {\n")
+    fmt.Fprintf(b, "    rankdir=LR;\n")
+    fmt.Fprintf(b, "    node [shape=circle, style=filled, fontsize=8, width=0.3, fixedsize=true];\n")
+    for _, t := range []int{neurontype.Sensory, neurontype.Mechanical} {
+        rank := "source"
+        if t == neurontype.Mechanical {
+            rank = "sink"
+        }
+        fmt.Fprintf(b, "    { rank=%s;", rank)
+        for _, n := range circuit.Cluster {
+            if n.Type == t {
+                fmt.Fprintf(b, " n%d;", n.Index)
+            }
+        }
+        fmt.Fprintf(b, " }\n")
+    }
+    for _, n := range circuit.Cluster {
+        peripheries := 1
+        if n.Index >= circuit.Initial {
+            peripheries = 2
+        }
+        fmt.Fprintf(b, "    n%d [label=\"%d\", fillcolor=\"%s\", peripheries=%d, tooltip=\"%s\"];\n", n.Index, n.Index, hexColour(exportColours[n.Type]), peripheries, typeName(n.Type))
+    }
+    for _, n := range circuit.Cluster {
+        for _, at := range n.Axon.Terminals {
+            if at.To == nil {
+                continue
+            }
+            colour, head := excitatoryColour, "normal"
+            if !at.SynapseIsExcitatory {
+                colour, head = inhibitoryColour, "tee"
+            }
+            fmt.Fprintf(b, "    n%d -> n%d [color=\"%s\", arrowhead=%s, penwidth=%.3f];\n", n.Index, at.To.PartOf.Index, hexColour(colour), head, at.Strength() / defaultSynapticWeight)
+        }
+    }
+    fmt.Fprintf(b, "}\n")
+    
+    return b.Flush()
+}
+
+// WriteGEXF writes the circuit as a GEXF 1.3 graph for Gephi. Neurons carry
+// their type, threshold and whether they were grown after Neurogenesis as
+// attributes, terminals their sign; colours are as in WriteDOT.
+func (circuit *Circuit) WriteGEXF(w io.Writer) error {
+    b := bufio.NewWriter(w)
+    
+    fmt.Fprintf(b, "%s", xml.Header)
+    fmt.Fprintf(b, "<gexf xmlns=\"http://gexf.net/1.3\" xmlns:viz=\"http://gexf.net/1.3/viz\" version=\"1.3\">\n")
+    fmt.Fprintf(b, "  <graph defaultedgetype=\"directed\">\n")
+    fmt.Fprintf(b, "    <attributes class=\"node\">\n")
+    fmt.Fprintf(b, "      <attribute id=\"type\" title=\"type\" type=\"string\"/>\n")
+    fmt.Fprintf(b, "      <attribute id=\"threshold\" title=\"threshold\" type=\"double\"/>\n")
+    fmt.Fprintf(b, "      <attribute id=\"grown\" title=\"grown\" type=\"boolean\"/>\n")
+    fmt.Fprintf(b, "    </attributes>\n")
+    fmt.Fprintf(b, "    <attributes class=\"edge\">\n")
+    fmt.Fprintf(b, "      <attribute id=\"sign\" title=\"sign\" type=\"string\"/>\n")
+    fmt.Fprintf(b, "    </attributes>\n")
+    
+    fmt.Fprintf(b, "    <nodes>\n")
+    for _, n := range circuit.Cluster {
+        c := exportColours[n.Type]
+        size := 1.0
+        if n.Index >= circuit.Initial {
+            size = 2.0
+        }
+        fmt.Fprintf(b, "      <node id=\"%d\" label=\"%d\">\n", n.Index, n.Index)
+        fmt.Fprintf(b, "        <attvalues>\n")
+        fmt.Fprintf(b, "          <attvalue for=\"type\" value=\"%s\"/>\n", typeName(n.Type))
+        fmt.Fprintf(b, "          <attvalue for=\"threshold\" value=\"%g\"/>\n", n.ThresholdPotential)
+        fmt.Fprintf(b, "          <attvalue for=\"grown\" value=\"%t\"/>\n", n.Index >= circuit.Initial)
+        fmt.Fprintf(b, "        </attvalues>\n")
+        fmt.Fprintf(b, "        <viz:color r=\"%d\" g=\"%d\" b=\"%d\"/>\n", c[0], c[1], c[2])
+        fmt.Fprintf(b, "        <viz:size value=\"%g\"/>\n", size)
+        fmt.Fprintf(b, "      </node>\n")
+    }
+    fmt.Fprintf(b, "    </nodes>\n")
+    
+    fmt.Fprintf(b, "    <edges>\n")
+    id := 0
+    for _, n := range circuit.Cluster {
+        for _, at := range n.Axon.Terminals {
+            if at.To == nil {
+                continue
+            }
+            c, sign := excitatoryColour, "excitatory"
+            if !at.SynapseIsExcitatory {
+                c, sign = inhibitoryColour, "inhibitory"
+            }
+            fmt.Fprintf(b, "      <edge id=\"%d\" source=\"%d\" target=\"%d\" weight=\"%g\">\n", id, n.Index, at.To.PartOf.Index, at.Strength())
+            fmt.Fprintf(b, "        <attvalues>\n")
+            fmt.Fprintf(b, "          <attvalue for=\"sign\" value=\"%s\"/>\n", sign)
+            fmt.Fprintf(b, "        </attvalues>\n")
+            fmt.Fprintf(b, "        <viz:color r=\"%d\" g=\"%d\" b=\"%d\"/>\n", c[0], c[1], c[2])
+            fmt.Fprintf(b, "      </edge>\n")
+            id += 1
+        }
+    }
+    fmt.Fprintf(b, "    </edges>\n")
+    fmt.Fprintf(b, "  </graph>\n")
+    fmt.Fprintf(b, "</gexf>\n")
+    
+    return b.Flush()
+}
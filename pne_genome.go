@@ -0,0 +1,194 @@
+package main
+
+import (
+    "fmt"
+)
+
+// Genome is the genotype of a circuit: what its neurons are and how they are
+// wired, without any of the state an exposure leaves behind. Neurons are
+// listed by index, so the deep neurons grown by learning come after Initial.
+type Genome struct {
+    In int
+    Out int
+    Initial int
+    MaxConn int
+    Inhibitors int
+    Params NeuronParams
+    Delays DelayDistribution
+    // models neurons grown from here on get, by type
+    Models map[int]ModelGene
+    Neurons []NeuronGene
+    Connections []ConnectionGene
+}
+
+type NeuronGene struct {
+    Innovation int
+    Type int
+    Model ModelGene
+    ThresholdPotential float64
+}
+
+// ConnectionGene is an axon terminal from neuron From onto dendrite Dendrite
+// of neuron To; To is -1 for terminals that found no vacant dendrite.
+type ConnectionGene struct {
+    Innovation int
+    From int
+    To int
+    Dendrite int
+    Excitatory bool
+    Weight float64
+    Efficacy float64
+    Delay float64
+}
+
+// ModelGene is a neuron model by name and parameters.
+type ModelGene struct {
+    Name string
+    Params []float64
+}
+
+// EncodeModel turns one of the models of this package into a gene.
+func EncodeModel(m NeuronModel) (ModelGene, error) {
+    switch m := m.(type) {
+    case ThresholdModel:
+        p := m.Params
+        return ModelGene{m.Name(), []float64{p.RestingPotential, p.ThresholdPotential, p.HyperpolarizedPotential, p.MembraneTimeConstant, p.RefractoryPeriod}}, nil
+    case IzhikevichModel:
+        return ModelGene{m.Name(), []float64{m.A, m.B, m.C, m.D, m.Peak, m.SynapticGain}}, nil
+    case AdExModel:
+        return ModelGene{m.Name(), []float64{m.Capacitance, m.LeakConductance, m.LeakReversal, m.Threshold, m.SlopeFactor, m.AdaptationTimeConstant, m.SubthresholdAdaptation, m.SpikeAdaptation, m.Reset, m.Peak, m.SynapticGain}}, nil
+    }
+    return ModelGene{}, fmt.Errorf("pne: can't encode neuron model %q", m.Name())
+}
+
+// Model is the neuron model the gene describes.
+func (g ModelGene) Model() (NeuronModel, error) {
+    want := map[string]int{"threshold": 5, "izhikevich": 6, "adex": 11}
+    if n, ok := want[g.Name]; !ok {
+        return nil, fmt.Errorf("pne: unknown neuron model %q", g.Name)
+    } else if len(g.Params) != n {
+        return nil, fmt.Errorf("pne: neuron model %q takes %d parameters, got %d", g.Name, n, len(g.Params))
+    }
+    
+    p := g.Params
+    switch g.Name {
+    case "threshold":
+        return ThresholdModel{NeuronParams{p[0], p[1], p[2], p[3], p[4]}}, nil
+    case "izhikevich":
+        return IzhikevichModel{p[0], p[1], p[2], p[3], p[4], p[5]}, nil
+    default:
+        return AdExModel{p[0], p[1], p[2], p[3], p[4], p[5], p[6], p[7], p[8], p[9], p[10]}, nil
+    }
+}
+
+// ToGenome encodes the circuit's structure. It fails only for neuron models
+// from outside this package.
+func (circuit *Circuit) ToGenome() (*Genome, error) {
+    g := &Genome{
+        In: circuit.In,
+        Out: circuit.Out,
+        Initial: circuit.Initial,
+        MaxConn: circuit.MaxConn,
+        Inhibitors: circuit.Inhibitors,
+        Params: circuit.Params,
+        Delays: circuit.Delays,
+    }
+    if circuit.Models != nil {
+        g.Models = make(map[int]ModelGene, len(circuit.Models))
+        for t, m := range circuit.Models {
+            mg, err := EncodeModel(m)
+            if err != nil {
+                return nil, err
+            }
+            g.Models[t] = mg
+        }
+    }
+    
+    for _, n := range circuit.Cluster {
+        mg, err := EncodeModel(n.Model)
+        if err != nil {
+            return nil, err
+        }
+        g.Neurons = append(g.Neurons, NeuronGene{n.Innovation, n.Type, mg, n.ThresholdPotential})
+    }
+    for _, n := range circuit.Cluster {
+        for _, at := range n.Axon.Terminals {
+            to, dendrite := -1, -1
+            if at.To != nil {
+                to, dendrite = at.To.PartOf.Index, at.To.Position()
+            }
+            g.Connections = append(g.Connections, ConnectionGene{at.Innovation, n.Index, to, dendrite, at.SynapseIsExcitatory, at.Weight, at.Efficacy, at.Delay})
+        }
+    }
+    
+    return g, nil
+}
+
+// NewCircuitFromGenome grows the circuit g describes, with every neuron at
+// rest. ToGenome of the result gives g back. New innovations of the circuit
+// are numbered after the genome's; to share markings with other circuits,
+// set Innovations.
+func NewCircuitFromGenome(g *Genome) (*Circuit, error) {
+    circuit := &Circuit{
+        In: g.In,
+        Out: g.Out,
+        Initial: g.Initial,
+        MaxConn: g.MaxConn,
+        Inhibitors: g.Inhibitors,
+        Params: g.Params,
+        Delays: g.Delays,
+        Innovations: &Innovations{},
+    }
+    if g.Models != nil {
+        circuit.Models = make(map[int]NeuronModel, len(g.Models))
+        for t, mg := range g.Models {
+            m, err := mg.Model()
+            if err != nil {
+                return nil, err
+            }
+            circuit.Models[t] = m
+        }
+    }
+    
+    for i, ng := range g.Neurons {
+        m, err := ng.Model.Model()
+        if err != nil {
+            return nil, err
+        }
+        n := &Neuron{}
+        circuit.Cluster = append(circuit.Cluster, n)
+        n.Genesis(i, ng.Type, circuit)
+        n.Model = m
+        n.Model.Genesis(n)
+        n.ThresholdPotential = ng.ThresholdPotential
+        n.Innovation = ng.Innovation
+        if ng.Innovation > circuit.Innovations.Next {
+            circuit.Innovations.Next = ng.Innovation
+        }
+    }
+    
+    for _, cg := range g.Connections {
+        if cg.From < 0 || cg.From >= len(circuit.Cluster) || cg.To >= len(circuit.Cluster) {
+            return nil, fmt.Errorf("pne: connection %d runs between neurons the genome doesn't have", cg.Innovation)
+        }
+        a := circuit.Cluster[cg.From].Axon
+        var to *Dendrite
+        if cg.To >= 0 {
+            dendrites := circuit.Cluster[cg.To].Dendrites
+            if cg.Dendrite < 0 || cg.Dendrite >= len(dendrites) {
+                return nil, fmt.Errorf("pne: connection %d ends on a dendrite the genome doesn't have", cg.Innovation)
+            }
+            to = &dendrites[cg.Dendrite]
+        }
+        at := &axonTerminal{a, to, cg.Excitatory, cg.Weight, cg.Efficacy, cg.Delay, 0, cg.Innovation}
+        a.Terminals = append(a.Terminals, at)
+        if to != nil {
+            to.PartOf.Afferents = append(to.PartOf.Afferents, at)
+        }
+        if cg.Innovation > circuit.Innovations.Next {
+            circuit.Innovations.Next = cg.Innovation
+        }
+    }
+    
+    return circuit, nil
+}
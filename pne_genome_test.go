@@ -0,0 +1,42 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+// A trained circuit, deep neurons grown by CorrectFor and all, survives the
+// trip through its genome unchanged.
+func TestGenomeRoundTrip(t *testing.T) {
+    stimuli, labels := numbers(t)
+    c := &Circuit{Models: map[int]NeuronModel{neurontype.Deep: IzhikevichRegularSpiking()}}
+    c.Neurogenesis(256, 10)
+    train(t, c, stimuli[:60], labels, 1)
+    if len(c.Cluster) == c.Initial {
+        t.Fatal("training grew no neurons")
+    }
+
+    g, err := c.ToGenome()
+    if err != nil {
+        t.Fatal(err)
+    }
+    d, err := NewCircuitFromGenome(g)
+    if err != nil {
+        t.Fatal(err)
+    }
+    h, err := d.ToGenome()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(g, h) {
+        t.Fatal("genome changed on the way through a circuit")
+    }
+
+    c.Rest()
+    d.Rest()
+    for _, stimulus := range stimuli[60:90] {
+        if a, b := c.ExposeTo(stimulus.GreyScale), d.ExposeTo(stimulus.GreyScale); !reflect.DeepEqual(a, b) {
+            t.Fatalf("%s: decoded circuit perceived %v instead of %v", stimulus.Path, b, a)
+        }
+    }
+}
@@ -0,0 +1,48 @@
+package main
+
+// Homeostasis is intrinsic plasticity: after every exposure the threshold of
+// each deep and mechanical neuron moves toward whatever makes it fire
+// TargetRate times per exposure. Sensory neurons encode the stimulus and are
+// left alone. Threshold and AdEx neurons spike at their ThresholdPotential;
+// Izhikevich neurons have it turned into an input current that moves their
+// threshold there.
+type Homeostasis struct {
+    // spikes per exposure each neuron should settle at
+    TargetRate float64
+    // threshold change per spike of difference between rate and target
+    LearningRate float64
+    // weight of the latest exposure in the running firing rate
+    Smoothing float64
+    MinThreshold float64
+    MaxThreshold float64
+}
+
+func DefaultHomeostasis() Homeostasis {
+    return Homeostasis{1, 0.005, 0.1, -0.65, -0.40}
+}
+
+// Adapt folds the spikes of the last exposure into every neuron's firing rate
+// and lets its threshold drift accordingly.
+func (circuit *Circuit) Adapt() {
+    h := circuit.Homeostasis
+    if h == nil {
+        return
+    }
+    
+    for _, n := range circuit.Cluster {
+        spikes := n.spikes
+        n.spikes = 0
+        if n.Type == neurontype.Sensory {
+            continue
+        }
+        
+        n.FiringRate += h.Smoothing * (float64(spikes) - n.FiringRate)
+        n.ThresholdPotential += h.LearningRate * (n.FiringRate - h.TargetRate)
+        if n.ThresholdPotential < h.MinThreshold {
+            n.ThresholdPotential = h.MinThreshold
+        }
+        if n.ThresholdPotential > h.MaxThreshold {
+            n.ThresholdPotential = h.MaxThreshold
+        }
+    }
+}
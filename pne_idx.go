@@ -0,0 +1,252 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "strconv"
+)
+
+var ErrIDXFormat = errors.New("pne: not an IDX file of unsigned bytes")
+
+// MNISTNames label MNIST digits the way the stimuli in data/numbers are named.
+var MNISTNames = []string{"ZERO", "ONE", "TWO", "THREE", "FOUR", "FIVE", "SIX", "SEVEN", "EIGHT", "NINE"}
+
+// idxFile is an IDX file of unsigned bytes being read item by item.
+type idxFile struct {
+    r *bufio.Reader
+    closers []io.Closer
+    dims []int
+    read int
+}
+
+// openIDX reads the header of an IDX file, which may be gzipped.
+func openIDX(r io.Reader, dims int) (*idxFile, error) {
+    f := &idxFile{r: bufio.NewReader(r)}
+    if magic, err := f.r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+        z, err := gzip.NewReader(f.r)
+        if err != nil {
+            return nil, err
+        }
+        f.r = bufio.NewReader(z)
+        f.closers = append(f.closers, z)
+    }
+    
+    var magic [4]byte
+    if _, err := io.ReadFull(f.r, magic[:]); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrIDXFormat, err)
+    }
+    if magic[0] != 0 || magic[1] != 0 || magic[2] != 0x08 || int(magic[3]) != dims {
+        return nil, fmt.Errorf("%w: magic %x", ErrIDXFormat, magic)
+    }
+    for i := 0; i < dims; i++ {
+        var d uint32
+        if err := binary.Read(f.r, binary.BigEndian, &d); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrIDXFormat, err)
+        }
+        f.dims = append(f.dims, int(d))
+    }
+    return f, nil
+}
+
+// IDXReader streams the images (and labels) of an IDX dataset such as MNIST,
+// EMNIST or Fashion-MNIST one at a time, as stimuli in the same units as
+// StimulusLoader's: ink is 0.2, background 0.
+type IDXReader struct {
+    // Count is how many images there are, Rows and Cols how big they are.
+    Count int
+    Rows int
+    Cols int
+    // if set, images are scaled down (or up) to a square of In pixels, e.g.
+    // 16x16 for a circuit grown with 256 sensory neurons
+    In int
+    // EMNIST stores its images transposed
+    Transpose bool
+    // if set, labels are named by Names, otherwise by their number
+    Names []string
+    images *idxFile
+    labels *idxFile
+    files []*os.File
+}
+
+// NewIDXReader reads images, and labels unless that is nil, from a pair of
+// IDX files.
+func NewIDXReader(images io.Reader, labels io.Reader) (*IDXReader, error) {
+    r := &IDXReader{}
+    var err error
+    if r.images, err = openIDX(images, 3); err != nil {
+        return nil, err
+    }
+    r.Count, r.Rows, r.Cols = r.images.dims[0], r.images.dims[1], r.images.dims[2]
+    if labels != nil {
+        if r.labels, err = openIDX(labels, 1); err != nil {
+            return nil, err
+        }
+        if r.labels.dims[0] != r.Count {
+            return nil, fmt.Errorf("%w: %d labels for %d images", ErrIDXFormat, r.labels.dims[0], r.Count)
+        }
+    }
+    return r, nil
+}
+
+// OpenIDX opens a pair of IDX files, e.g. train-images-idx3-ubyte.gz and
+// train-labels-idx1-ubyte.gz; labels may be left empty. Read them again for
+// another epoch.
+func OpenIDX(images string, labels string) (*IDXReader, error) {
+    if images == "" {
+        return nil, fmt.Errorf("pne: no IDX images given")
+    }
+    var files []*os.File
+    closeAll := func() {
+        for _, f := range files {
+            f.Close()
+        }
+    }
+    for _, path := range []string{images, labels} {
+        if path == "" {
+            continue
+        }
+        f, err := os.Open(path)
+        if err != nil {
+            closeAll()
+            return nil, err
+        }
+        files = append(files, f)
+    }
+    
+    var l io.Reader
+    if len(files) > 1 {
+        l = files[1]
+    }
+    r, err := NewIDXReader(files[0], l)
+    if err != nil {
+        closeAll()
+        return nil, fmt.Errorf("pne: reading %s: %w", images, err)
+    }
+    r.files = files
+    return r, nil
+}
+
+// Next returns the next image, or io.EOF once all have been read.
+func (r *IDXReader) Next() (ImgStimulus, error) {
+    if r.images.read >= r.Count {
+        return ImgStimulus{}, io.EOF
+    }
+    side := 0
+    if r.In > 0 {
+        side = int(math.Sqrt(float64(r.In)))
+        if side * side != r.In {
+            return ImgStimulus{}, fmt.Errorf("pne: can't scale images to %d pixels, as it isn't a square", r.In)
+        }
+    }
+    
+    pixels := make([]byte, r.Rows * r.Cols)
+    if _, err := io.ReadFull(r.images.r, pixels); err != nil {
+        return ImgStimulus{}, fmt.Errorf("%w: image %d: %v", ErrIDXFormat, r.images.read, err)
+    }
+    index := r.images.read
+    r.images.read += 1
+    
+    rows, cols := r.Rows, r.Cols
+    Greyscale := make([]float64, len(pixels))
+    for y := 0; y < rows; y++ {
+        for x := 0; x < cols; x++ {
+            ink := float64(pixels[y * cols + x]) / 255 * 0.2
+            if r.Transpose {
+                Greyscale[x * rows + y] = ink
+            } else {
+                Greyscale[y * cols + x] = ink
+            }
+        }
+    }
+    if r.Transpose {
+        rows, cols = cols, rows
+    }
+    if side > 0 {
+        Greyscale = resize(Greyscale, cols, rows, side, side)
+    }
+    
+    Type := ""
+    if r.labels != nil {
+        label, err := r.labels.r.ReadByte()
+        if err != nil {
+            return ImgStimulus{}, fmt.Errorf("%w: label %d: %v", ErrIDXFormat, index, err)
+        }
+        r.labels.read += 1
+        if int(label) < len(r.Names) {
+            Type = r.Names[label]
+        } else {
+            Type = strconv.Itoa(int(label))
+        }
+    }
+    
+    return ImgStimulus{Type, strconv.Itoa(index), "", Greyscale}, nil
+}
+
+// Each calls f with every image left to read, until f returns an error.
+func (r *IDXReader) Each(f func(ImgStimulus) error) error {
+    for {
+        stimulus, err := r.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if err := f(stimulus); err != nil {
+            return err
+        }
+    }
+}
+
+// Close closes the files OpenIDX opened.
+func (r *IDXReader) Close() error {
+    var err error
+    for _, f := range []*idxFile{r.images, r.labels} {
+        if f == nil {
+            continue
+        }
+        for _, c := range f.closers {
+            if cerr := c.Close(); err == nil {
+                err = cerr
+            }
+        }
+    }
+    for _, f := range r.files {
+        if cerr := f.Close(); err == nil {
+            err = cerr
+        }
+    }
+    return err
+}
+
+// resize scales a w x h image to nw x nh. Every pixel of the result is the
+// average of the pixels it covers, weighted by how much of them it covers.
+func resize(pixels []float64, w int, h int, nw int, nh int) []float64 {
+    out := make([]float64, nw * nh)
+    sx, sy := float64(w) / float64(nw), float64(h) / float64(nh)
+    for y := 0; y < nh; y++ {
+        y0, y1 := float64(y) * sy, float64(y + 1) * sy
+        for x := 0; x < nw; x++ {
+            x0, x1 := float64(x) * sx, float64(x + 1) * sx
+            sum, area := 0.0, 0.0
+            for py := int(y0); py < h && float64(py) < y1; py++ {
+                fy := math.Min(y1, float64(py + 1)) - math.Max(y0, float64(py))
+                for px := int(x0); px < w && float64(px) < x1; px++ {
+                    fx := math.Min(x1, float64(px + 1)) - math.Max(x0, float64(px))
+                    sum += pixels[py * w + px] * fx * fy
+                    area += fx * fy
+                }
+            }
+            if area > 0 {
+                out[y * nw + x] = sum / area
+            }
+        }
+    }
+    return out
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "errors"
+    "io"
+    "math"
+    "reflect"
+    "testing"
+)
+
+// idx writes an IDX file of unsigned bytes with the given dimensions.
+func idx(dims []int, data []byte) []byte {
+    var b bytes.Buffer
+    b.Write([]byte{0, 0, 0x08, byte(len(dims))})
+    for _, d := range dims {
+        binary.Write(&b, binary.BigEndian, uint32(d))
+    }
+    b.Write(data)
+    return b.Bytes()
+}
+
+func gzipped(data []byte) []byte {
+    var b bytes.Buffer
+    z := gzip.NewWriter(&b)
+    z.Write(data)
+    z.Close()
+    return b.Bytes()
+}
+
+// Two 2x3 images, the second with its labels gzipped; EMNIST's transposed
+// images come out turned the right way.
+func TestIDXReader(t *testing.T) {
+    images := idx([]int{2, 2, 3}, []byte{255, 0, 0, 0, 0, 255, 0, 255, 0, 0, 255, 0})
+    labels := gzipped(idx([]int{2}, []byte{1, 7}))
+
+    r, err := NewIDXReader(bytes.NewReader(images), bytes.NewReader(labels))
+    if err != nil {
+        t.Fatal(err)
+    }
+    r.Names = MNISTNames[:2]
+    if r.Count != 2 || r.Rows != 2 || r.Cols != 3 {
+        t.Fatalf("read %d images of %dx%d, want 2 of 2x3", r.Count, r.Rows, r.Cols)
+    }
+    var got []ImgStimulus
+    if err := r.Each(func(s ImgStimulus) error {
+        got = append(got, s)
+        return nil
+    }); err != nil {
+        t.Fatal(err)
+    }
+    want := []ImgStimulus{
+        {"ONE", "0", "", []float64{0.2, 0, 0, 0, 0, 0.2}},
+        {"7", "1", "", []float64{0, 0.2, 0, 0, 0.2, 0}},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("read %v, want %v", got, want)
+    }
+    if _, err := r.Next(); err != io.EOF {
+        t.Fatalf("after the last image: got %v, want io.EOF", err)
+    }
+
+    r, err = NewIDXReader(bytes.NewReader(gzipped(images)), nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    r.Transpose = true
+    r.Next()
+    s, err := r.Next()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // stored column by column, so the 2x3 image is really 3 rows of 2
+    if want := []float64{0, 0, 0.2, 0.2, 0, 0}; s.Type != "" || !reflect.DeepEqual(s.GreyScale, want) {
+        t.Fatalf("transposed image read as %q %v, want %v", s.Type, s.GreyScale, want)
+    }
+}
+
+func TestIDXReaderRejectsBadFiles(t *testing.T) {
+    images := idx([]int{2, 2, 2}, make([]byte, 8))
+    for name, files := range map[string][2][]byte{
+        "magic": {idx([]int{2, 2}, make([]byte, 4)), nil},
+        "labels": {images, idx([]int{3}, make([]byte, 3))},
+        "header": {images[:6], nil},
+    } {
+        var labels io.Reader
+        if files[1] != nil {
+            labels = bytes.NewReader(files[1])
+        }
+        if _, err := NewIDXReader(bytes.NewReader(files[0]), labels); !errors.Is(err, ErrIDXFormat) {
+            t.Errorf("%s: got %v, want ErrIDXFormat", name, err)
+        }
+    }
+}
+
+// Resizing averages the pixels covered, in part where they are covered in
+// part.
+func TestResize(t *testing.T) {
+    pixels := []float64{
+        1, 1, 0, 0,
+        1, 1, 0, 0,
+        0, 0, 0, 0,
+        0, 0, 0, 1,
+    }
+    if got, want := resize(pixels, 4, 4, 2, 2), []float64{1, 0, 0, 0.25}; !reflect.DeepEqual(got, want) {
+        t.Errorf("4x4 to 2x2: got %v, want %v", got, want)
+    }
+    got := resize([]float64{0, 1}, 2, 1, 3, 1)
+    want := []float64{0, 0.5, 1}
+    for i := range want {
+        if math.Abs(got[i] - want[i]) > 1e-12 {
+            t.Fatalf("2x1 to 3x1: got %v, want %v", got, want)
+        }
+    }
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sync"
+)
+
+// Innovations hands out NEAT's historical markings. The same structural change
+// gets the same number in every circuit sharing the tracker, which is what lets
+// two circuits' genes be lined up.
+type Innovations struct {
+    Next int
+    Markings map[string]int
+    mu sync.Mutex
+}
+
+func (in *Innovations) Mark(key string) int {
+    in.mu.Lock()
+    defer in.mu.Unlock()
+    
+    if in.Markings == nil {
+        in.Markings = make(map[string]int)
+    }
+    if m, ok := in.Markings[key]; ok {
+        return m
+    }
+    in.Next += 1
+    in.Markings[key] = in.Next
+    return in.Next
+}
+
+// innovate marks a change described by key. A circuit may make the same change
+// several times (CorrectFor regrows between the same neurons), so repeats are
+// numbered: the circuit's k-th repeat of a change gets the same marking as
+// every other circuit's k-th. Markings the circuit already uses are skipped,
+// as they are after loading a circuit, whose repeats are counted afresh.
+func (circuit *Circuit) innovate(key string) int {
+    if circuit.Innovations == nil {
+        circuit.Innovations = &Innovations{}
+    }
+    if circuit.marked == nil {
+        circuit.marked = make(map[int]bool)
+        circuit.repeats = make(map[string]int)
+        for _, n := range circuit.Cluster {
+            circuit.marked[n.Innovation] = true
+            for _, at := range n.Axon.Terminals {
+                circuit.marked[at.Innovation] = true
+            }
+        }
+    }
+    for {
+        k := circuit.repeats[key]
+        circuit.repeats[key] = k + 1
+        m := circuit.Innovations.Mark(fmt.Sprintf("%s #%d", key, k))
+        if !circuit.marked[m] {
+            circuit.marked[m] = true
+            return m
+        }
+    }
+}
+
+func (circuit *Circuit) neuronInnovation(index int, between []int) int {
+    if len(between) < 2 {
+        return circuit.innovate(fmt.Sprintf("neuron %d", index))
+    }
+    from, to := circuit.Cluster[between[0]].Innovation, circuit.Cluster[between[1]].Innovation
+    return circuit.innovate(fmt.Sprintf("neuron %d-%d", from, to))
+}
+
+func (a *axon) terminalInnovation(to *Dendrite, exc bool) int {
+    if to == nil {
+        return 0
+    }
+    sign := "-"
+    if exc {
+        sign = "+"
+    }
+    return a.From.circuit.innovate(fmt.Sprintf("terminal %d-%d %s", a.From.Innovation, to.PartOf.Innovation, sign))
+}
+
+// Compatibility weighs the differences between two circuits' genes: the number
+// of excess and disjoint terminals, and the average difference in signed
+// strength (in units of a fresh synapse) of the terminals they share. Circuits
+// closer than Threshold belong to the same species.
+type Compatibility struct {
+    Excess float64
+    Disjoint float64
+    Weight float64
+    Threshold float64
+}
+
+func DefaultCompatibility() Compatibility {
+    return Compatibility{0.1, 0.1, 0.4, 3}
+}
+
+func (at *axonTerminal) signedStrength() float64 {
+    if at.SynapseIsExcitatory {
+        return at.Strength()
+    }
+    return -at.Strength()
+}
+
+func (circuit *Circuit) genes() map[int]*axonTerminal {
+    genes := make(map[int]*axonTerminal)
+    for _, n := range circuit.Cluster {
+        for _, at := range n.Axon.Terminals {
+            genes[at.Innovation] = at
+        }
+    }
+    return genes
+}
+
+// Distance is NEAT's compatibility distance between two circuits.
+func (circuit *Circuit) Distance(other *Circuit, k Compatibility) float64 {
+    a, b := circuit.genes(), other.genes()
+    maxA, maxB := 0, 0
+    for m := range a {
+        if m > maxA {
+            maxA = m
+        }
+    }
+    for m := range b {
+        if m > maxB {
+            maxB = m
+        }
+    }
+    
+    excess, disjoint, matching := 0, 0, 0
+    weight := float64(0)
+    count := func(mine map[int]*axonTerminal, theirs map[int]*axonTerminal, theirMax int) {
+        for m := range mine {
+            if _, ok := theirs[m]; ok {
+                continue
+            }
+            if m > theirMax {
+                excess += 1
+            } else {
+                disjoint += 1
+            }
+        }
+    }
+    count(a, b, maxB)
+    count(b, a, maxA)
+    for m, at := range a {
+        if bt, ok := b[m]; ok {
+            matching += 1
+            weight += math.Abs(at.signedStrength() - bt.signedStrength()) / defaultSynapticWeight
+        }
+    }
+    
+    // unlike NEAT, counts aren't normalised by genome size: every circuit
+    // carries the same large sensory wiring, which would drown out the
+    // structure that sets circuits apart
+    d := k.Excess * float64(excess) + k.Disjoint * float64(disjoint)
+    if matching > 0 {
+        d += k.Weight * weight / float64(matching)
+    }
+    return d
+}
+
+// Crossover breeds a child from a fitter and a weaker parent. Genes are lined
+// up by innovation: the child inherits the fitter parent's structure, and each
+// matching neuron and terminal takes its parameters from either parent at random.
+func Crossover(fitter *Circuit, weaker *Circuit, seed int64) *Circuit {
+    child := fitter.Clone()
+    child.Seed = seed
+    r := child.Rand()
+    
+    neurons := make(map[int]*Neuron)
+    for _, n := range weaker.Cluster {
+        neurons[n.Innovation] = n
+    }
+    terminals := weaker.genes()
+    
+    for _, n := range child.Cluster {
+        if o, ok := neurons[n.Innovation]; ok && r.Float64() < 0.5 {
+            n.ThresholdPotential = o.ThresholdPotential
+        }
+        for _, at := range n.Axon.Terminals {
+            if o, ok := terminals[at.Innovation]; ok && r.Float64() < 0.5 {
+                at.SynapseIsExcitatory = o.SynapseIsExcitatory
+                at.Weight = o.Weight
+                at.Efficacy = o.Efficacy
+                at.Delay = o.Delay
+            }
+        }
+    }
+    
+    return child
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "math"
+)
+
+// IzhikevichModel is the simple spiking model of Izhikevich (2003). A, B, C, D
+// and Peak are in the units of the paper (mV, ms); the neuron's potentials are
+// kept in circuit units, i.e. mV / 100. SynapticGain scales every input, see
+// gain.
+type IzhikevichModel struct {
+    A, B, C, D float64
+    Peak float64
+    SynapticGain float64
+}
+
+const izhikevichStep = 0.25
+
+// The presets' gains are calibrated to the circuits Neurogenesis grows, where
+// deep neurons start out with two sensory synapses: with them, nearly every
+// stimulus in data/numbers is perceived.
+
+func IzhikevichRegularSpiking() IzhikevichModel {
+    return IzhikevichModel{0.02, 0.2, -65, 8, 30, 4}
+}
+
+func IzhikevichFastSpiking() IzhikevichModel {
+    return IzhikevichModel{0.1, 0.2, -65, 2, 30, 2}
+}
+
+// IzhikevichBursting is the chattering preset: a reset close to threshold and
+// little adaptation make it fire several spikes per activation.
+func IzhikevichBursting() IzhikevichModel {
+    return IzhikevichModel{0.02, 0.2, -50, 2, 30, 3}
+}
+
+func (m IzhikevichModel) Name() string {
+    return "izhikevich"
+}
+
+// derivativesFor are the model's equations with the input current that puts
+// the neuron's threshold at its ThresholdPotential, see current.
+func (m IzhikevichModel) derivativesFor(n *Neuron) derivatives {
+    i := m.current(n)
+    return func(v float64, u float64) (float64, float64) {
+        return 0.04 * v * v + 5 * v + 140 - u + i, m.A * (m.B * v - u)
+    }
+}
+
+// fixed points of the model without input lie symmetrically about middle, if
+// the discriminant isn't negative
+func (m IzhikevichModel) middle() float64 {
+    return -(5 - m.B) / (2 * 0.04)
+}
+
+func (m IzhikevichModel) discriminant() float64 {
+    return (5 - m.B) * (5 - m.B) - 4 * 0.04 * 140
+}
+
+// rest is the stable fixed point without input, or C if there is none.
+func (m IzhikevichModel) rest() float64 {
+    disc := m.discriminant()
+    if disc < 0 {
+        return m.C
+    }
+    return m.middle() - math.Sqrt(disc) / (2 * 0.04)
+}
+
+// threshold is the unstable fixed point, above which the neuron goes on to
+// spike without further input, or Peak if there is none.
+func (m IzhikevichModel) threshold() float64 {
+    disc := m.discriminant()
+    if disc < 0 {
+        return m.Peak
+    }
+    return m.middle() + math.Sqrt(disc) / (2 * 0.04)
+}
+
+// current is the constant input that moves the unstable fixed point to the
+// neuron's ThresholdPotential, so that homeostasis makes it more or less
+// excitable; it is 0 while the threshold is where Genesis put it. A threshold
+// below the middle would take the neuron past the bifurcation into tonic
+// firing, so it counts as the middle.
+func (m IzhikevichModel) current(n *Neuron) float64 {
+    if m.discriminant() < 0 {
+        return 0
+    }
+    vt := math.Max(n.ThresholdPotential * 100, m.middle())
+    return -(0.04 * vt * vt + (5 - m.B) * vt + 140)
+}
+
+// Genesis sets ThresholdPotential to the model's threshold. Moving it (as
+// Homeostasis does) moves the threshold by way of an input current.
+func (m IzhikevichModel) Genesis(n *Neuron) {
+    n.ThresholdPotential = m.threshold() / 100
+    m.AssumeRestingPotential(n)
+}
+
+// AssumeRestingPotential puts the membrane at the stable fixed point, which
+// mirrors the threshold about the middle.
+func (m IzhikevichModel) AssumeRestingPotential(n *Neuron) {
+    v := m.rest()
+    if m.discriminant() >= 0 {
+        v = 2 * m.middle() - math.Max(n.ThresholdPotential * 100, m.middle())
+    }
+    n.MembranePotential = v / 100
+    n.Recovery = m.B * v
+    n.InRefractoryPeriod = false
+}
+
+func (m IzhikevichModel) Hyperpolarization(n *Neuron, now float64) {
+    evolve(n, now, izhikevichStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential = m.C / 100
+    n.Recovery += m.D
+}
+
+func (m IzhikevichModel) Inhibit(n *Neuron, amount float64, now float64) {
+    evolve(n, now, izhikevichStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential -= amount * gain(m.SynapticGain)
+}
+
+func (m IzhikevichModel) Excite(n *Neuron, amount float64, now float64) (bool, float64) {
+    evolve(n, now, izhikevichStep, m.Peak, m.derivativesFor(n))
+    n.MembranePotential += amount * gain(m.SynapticGain)
+    return upcoming(n, izhikevichStep, m.Peak, m.derivativesFor(n))
+}
+
+func (m IzhikevichModel) Activate(n *Neuron, now float64) (bool, float64) {
+    m.Hyperpolarization(n, now)
+    return upcoming(n, izhikevichStep, m.Peak, m.derivativesFor(n))
+}
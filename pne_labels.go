@@ -0,0 +1,98 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// LabelSet numbers the labels of a dataset: outcome i is Names[i]. A circuit
+// has as many mechanical neurons as its LabelSet has labels.
+type LabelSet struct {
+    Names []string
+}
+
+// LabelsOf collects the labels of stimuli. Labels already in known keep their
+// outcomes; the others follow in sorted order, so the same dataset always
+// gets the same outcomes however it is loaded.
+func LabelsOf(stimuli []ImgStimulus, known ... *LabelSet) *LabelSet {
+    s := &LabelSet{}
+    if len(known) > 0 && known[0] != nil {
+        s.Names = append(s.Names, known[0].Names...)
+    }
+    var found []string
+    for _, stimulus := range stimuli {
+        if _, ok := s.Outcome(stimulus.Type); !ok {
+            found = append(found, stimulus.Type)
+            s.Names = append(s.Names, stimulus.Type)
+        }
+    }
+    sort.Strings(found)
+    copy(s.Names[len(s.Names) - len(found):], found)
+    return s
+}
+
+// ReadLabelSet reads labels one per line, the first being outcome 0. Blank
+// lines are skipped.
+func ReadLabelSet(r io.Reader) (*LabelSet, error) {
+    s := &LabelSet{}
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        name := strings.TrimSpace(scanner.Text())
+        if name == "" {
+            continue
+        }
+        if _, ok := s.Outcome(name); ok {
+            return nil, fmt.Errorf("pne: label %q is listed twice", name)
+        }
+        s.Names = append(s.Names, name)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// LoadLabelSet reads a labels file, see ReadLabelSet.
+func LoadLabelSet(path string) (*LabelSet, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadLabelSet(f)
+}
+
+// Write writes the labels in the form ReadLabelSet reads.
+func (s *LabelSet) Write(w io.Writer) error {
+    b := bufio.NewWriter(w)
+    for _, name := range s.Names {
+        fmt.Fprintln(b, name)
+    }
+    return b.Flush()
+}
+
+func (s *LabelSet) Len() int {
+    return len(s.Names)
+}
+
+// Outcome is the outcome of the label name.
+func (s *LabelSet) Outcome(name string) (int, bool) {
+    for o, n := range s.Names {
+        if n == name {
+            return o, true
+        }
+    }
+    return -1, false
+}
+
+// Name is the label of outcome, or "" if there is none.
+func (s *LabelSet) Name(outcome int) string {
+    if outcome < 0 || outcome >= len(s.Names) {
+        return ""
+    }
+    return s.Names[outcome]
+}
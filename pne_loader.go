@@ -0,0 +1,147 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "image"
+    _ "image/gif"
+    _ "image/jpeg"
+    _ "image/png"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var ErrNoStimuli = errors.New("pne: no stimuli found")
+
+type LoadStimulus struct {
+    Type string
+    Variant string
+    Path string
+}
+
+type ImgStimulus struct {
+    Type string
+    Variant string
+    Path string
+    GreyScale []float64
+}
+
+type Pixel struct {
+    R, G, B, A int
+}
+
+// StimulusLoader loads the images under Dir whose extension is one of
+// Extensions. A stimulus' label (Type) and variant are read off its file name:
+// they are whatever Label and Variant match in it, run together, so that
+// "SEVEN12.png" is a SEVEN, variant 12 with the default expressions. With
+// LabelFromDir, the label is the name of the directory the image is in
+// instead, as in datasets sorted into one directory per class.
+//
+// PNG, JPEG and GIF images can be read. Every image goes through
+// Preprocessing, or DefaultPreprocessing if that is nil, on its way to
+// becoming a stimulus.
+type StimulusLoader struct {
+    Dir string
+    Extensions []string
+    Label *regexp.Regexp
+    Variant *regexp.Regexp
+    LabelFromDir bool
+    Preprocessing *Preprocessing
+}
+
+func DefaultStimulusLoader(dir string) StimulusLoader {
+    return StimulusLoader{dir, []string{".png", ".jpg", ".jpeg", ".gif"}, regexp.MustCompile(`[a-zA-Z]+`), regexp.MustCompile(`[0-9]+`), false, nil}
+}
+
+func (l StimulusLoader) accepts(path string) bool {
+    for _, ext := range l.Extensions {
+        if strings.EqualFold(filepath.Ext(path), ext) {
+            return true
+        }
+    }
+    return false
+}
+
+// Files lists the stimuli under Dir without loading them, in lexical order.
+func (l StimulusLoader) Files() ([]LoadStimulus, error) {
+    files := []LoadStimulus{}
+    
+    err := filepath.Walk(l.Dir, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if !info.IsDir() && l.accepts(path) {
+            name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+            Type := strings.Join(l.Label.FindAllString(name, -1), "")
+            if l.LabelFromDir {
+                Type = filepath.Base(filepath.Dir(path))
+            }
+            Variant := strings.Join(l.Variant.FindAllString(name, -1), "")
+            files = append(files, LoadStimulus{Type, Variant, path})
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("pne: loading stimuli from %s: %w", l.Dir, err)
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("%w in %s with extensions %v", ErrNoStimuli, l.Dir, l.Extensions)
+    }
+    return files, nil
+}
+
+// Load reads every stimulus as greyscale. It stops at the first image that
+// can't be read.
+func (l StimulusLoader) Load() ([]ImgStimulus, error) {
+    files, err := l.Files()
+    if err != nil {
+        return nil, err
+    }
+    
+    p := DefaultPreprocessing()
+    if l.Preprocessing != nil {
+        p = *l.Preprocessing
+    }
+    
+    images := []ImgStimulus{}
+    for _, stimulus := range files {
+        Greyscale, err := loadGreyScale(stimulus.Path, p)
+        if err != nil {
+            return nil, fmt.Errorf("pne: loading stimulus %s: %w", stimulus.Path, err)
+        }
+        images = append(images, ImgStimulus{stimulus.Type, stimulus.Variant, stimulus.Path, Greyscale})
+    }
+    
+    return images, nil
+}
+
+func loadGreyScale(path string, p Preprocessing) ([]float64, error) {
+    reader, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer reader.Close()
+    
+    img, _, err := image.Decode(reader)
+    if err != nil {
+        return nil, err
+    }
+    return p.Process(FrameOf(img)), nil
+}
+
+func RGBAToPixel(r uint32, g uint32, b uint32, a uint32) Pixel {
+    return Pixel{int(r / 257), int(g / 257), int(b / 257), int(a / 257)}
+}
+
+func PixelToGS(pixel Pixel) int {
+    return int(float64(pixel.R) * 0.299 + float64(pixel.G) * 0.587 + float64(pixel.B) * 0.114)
+}
+
+func GSToGR(gs int) float64 {
+    // this makes white the priority. we want black to be the priority.
+    //return (float64(gs) / float64(255)) * 0.2
+    bs := 255 - gs
+    return (float64(bs) / float64(255)) * 0.2
+}
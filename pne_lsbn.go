@@ -4,7 +4,13 @@ import (
     //"fmt"
     //"gonum.org/v1/gonum/mat"
     //"gonum.org/v1/gonum/stat"
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/fnv"
     "math"
+    "os"
 )
 
 /**
@@ -33,6 +39,10 @@ type LSBNCircuit struct {
 type LargeScaleBrainNetwork struct {
     IsSetup bool
     Circuits map[string]*LSBNCircuit
+    // circuits grown from here on learn with this rule alongside CorrectFor
+    STDP *STDP
+    // if set, TrainCircuit checkpoints its progress and resumes from there
+    Checkpoints *Checkpointer
 }
 
 func (lsbn *LargeScaleBrainNetwork) ForceGenesis () {
@@ -119,7 +129,7 @@ func (lsbn *LargeScaleBrainNetwork) GrowCircuit (identifier string, chunk_length
     types, chunks := lsbn.MakeChunks(chunk_length, chunk_beta, stim_length, stimuli)
     
     // setup circuit
-    (*lsbn).Circuits[identifier] = &LSBNCircuit{identifier, &Circuit{}, chunks, stim_length, chunk_length, chunk_beta, &stimuli, types, ""}
+    (*lsbn).Circuits[identifier] = &LSBNCircuit{identifier, &Circuit{STDP: lsbn.STDP}, chunks, stim_length, chunk_length, chunk_beta, &stimuli, types, ""}
     (*lsbn).Circuits[identifier].Circuit.Neurogenesis(chunk_length, types)
     
     if train {
@@ -188,7 +198,57 @@ func (lsbn *LargeScaleBrainNetwork) MakeChunks (chunk_length int, chunk_beta flo
 }
 
 func (lsbn *LargeScaleBrainNetwork) TrainCircuit (identifier string, alpha float64) bool {
+    return lsbn.TrainCircuitContext(context.Background(), identifier, alpha) == nil
+}
+
+// dataset identifies what the circuit is trained on: its chunking and the raw
+// stimuli the chunks were cut from.
+func (lc *LSBNCircuit) dataset() string {
+    h := fnv.New64a()
+    if lc.RawStimuli != nil {
+        var b [8]byte
+        for _, v := range *lc.RawStimuli {
+            binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+            h.Write(b[:])
+        }
+    }
+    return fmt.Sprintf("lsbn %d/%d beta %g types %d stimuli %016x", lc.StimLength, lc.ChunkLength, lc.ChunkBeta, lc.Types, h.Sum64())
+}
+
+// resumable checks that a checkpoint of the circuit was written for the
+// circuit as it is grown now, on the same stimuli and chunks.
+func (lc *LSBNCircuit) resumable(c *Circuit, state TrainingState) error {
+    if state.Dataset != lc.dataset() {
+        return fmt.Errorf("it was trained on %q, not %q", state.Dataset, lc.dataset())
+    }
+    if c.In != lc.Circuit.In || c.Out != lc.Circuit.Out {
+        return fmt.Errorf("its circuit has %d inputs and %d outputs, not %d and %d", c.In, c.Out, lc.Circuit.In, lc.Circuit.Out)
+    }
+    return nil
+}
+
+// TrainCircuitContext trains until alpha is reached or ctx is done. Exposures
+// cut short by the circuit's budgets are still corrected for. With Checkpoints
+// set, training picks up from the latest checkpoint of the same circuit, which
+// must have been grown from the same stimuli and chunks, and the checkpoint is
+// removed once alpha is reached.
+func (lsbn *LargeScaleBrainNetwork) TrainCircuitContext (ctx context.Context, identifier string, alpha float64) error {
     success_rate := float64(0)
+    epoch := 0
+    
+    if cp := lsbn.Checkpoints; cp != nil {
+        c, state, err := cp.Resume()
+        if err == nil && state.Circuit == identifier {
+            if err := (*lsbn).Circuits[identifier].resumable(c, state); err != nil {
+                return fmt.Errorf("pne: can't resume %s from %s: %w", identifier, cp.Path, err)
+            }
+            (*lsbn).Circuits[identifier].Circuit = c
+            epoch = state.Epoch
+            success_rate = state.SuccessRate
+        } else if err != nil && !errors.Is(err, os.ErrNotExist) {
+            return err
+        }
+    }
     
     for success_rate < alpha {
         total := 0
@@ -196,7 +256,14 @@ func (lsbn *LargeScaleBrainNetwork) TrainCircuit (identifier string, alpha float
                 
         for i := 0; i < len((*lsbn).Circuits[identifier].Data); i++ {
             total += 1
-            res := (*lsbn).Circuits[identifier].Circuit.ExposeTo((*lsbn).Circuits[identifier].Data[i].Sensations)
+            res, err := (*lsbn).Circuits[identifier].Circuit.ExposeToContext(ctx, (*lsbn).Circuits[identifier].Data[i].Sensations)
+            if ctx.Err() != nil {
+                // cancelled after the exposure's last spike, so it didn't notice
+                if err == nil {
+                    err = fmt.Errorf("pne: training %s stopped: %w", identifier, ctx.Err())
+                }
+                return err
+            }
             if len(res) > 0 {
                 if res[0].outcome == (*lsbn).Circuits[identifier].Data[i].Type {
                     correct += 1
@@ -206,15 +273,29 @@ func (lsbn *LargeScaleBrainNetwork) TrainCircuit (identifier string, alpha float
         }
         
         success_rate = float64(correct) / float64(total)
+        epoch += 1
+        
+        if cp := lsbn.Checkpoints; cp != nil && success_rate < alpha && cp.Due(epoch) {
+            if err := cp.Save((*lsbn).Circuits[identifier].Circuit, TrainingState{identifier, epoch, 0, 0, 0, success_rate, 0, (*lsbn).Circuits[identifier].dataset()}); err != nil {
+                return err
+            }
+        }
     }
     
-    return true
+    // training is done, nothing to resume any more
+    if cp := lsbn.Checkpoints; cp != nil {
+        if err := os.Remove(cp.Path); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+    }
+    
+    return nil
 }
 
 func (lsbn *LargeScaleBrainNetwork) Grow (recursion string, circuit string, outs int) {
     (*lsbn).Circuits[recursion] = &LSBNCircuit{}
     (*lsbn).Circuits[recursion].Identifier = recursion
-    (*lsbn).Circuits[recursion].Circuit = &Circuit{}
+    (*lsbn).Circuits[recursion].Circuit = &Circuit{STDP: lsbn.STDP}
     (*lsbn).Circuits[recursion].StimLength = (*lsbn).Circuits[circuit].Types * ((*lsbn).Circuits[circuit].StimLength / (*lsbn).Circuits[circuit].ChunkLength)
     (*lsbn).Circuits[recursion].ChunkLength = (*lsbn).Circuits[recursion].StimLength
     (*lsbn).Circuits[recursion].RawStimuli = (*lsbn).Circuits[circuit].RawStimuli
@@ -223,15 +304,32 @@ func (lsbn *LargeScaleBrainNetwork) Grow (recursion string, circuit string, outs
 }
 
 func (lsbn *LargeScaleBrainNetwork) Expose (circuit string, stimulus []float64) ([]float64, []RankedResult) {
+    ins, res, _ := lsbn.ExposeContext(context.Background(), circuit, stimulus)
+    return ins, res
+}
+
+// ExposeContext is Expose, but stops as soon as ctx is done. The first error
+// any of the circuits ran into is returned along with the results.
+func (lsbn *LargeScaleBrainNetwork) ExposeContext (ctx context.Context, circuit string, stimulus []float64) ([]float64, []RankedResult, error) {
     r := (*lsbn).Circuits[circuit]
     c := (*lsbn).Circuits[(*lsbn).Circuits[circuit].ConnectsTo]
     _, stim := lsbn.MakeChunks(c.ChunkLength, c.ChunkBeta, c.StimLength, stimulus)
     
     var ins []float64
+    var stopped error
     for _, s := range stim {
         in := make([]float64, c.Types)
         
-        res := c.Circuit.ExposeTo(s.Sensations)
+        res, err := c.Circuit.ExposeToContext(ctx, s.Sensations)
+        if err != nil && stopped == nil {
+            stopped = err
+        }
+        if ctx.Err() != nil {
+            if stopped == nil {
+                stopped = fmt.Errorf("pne: exposure of %s stopped: %w", circuit, ctx.Err())
+            }
+            return ins, nil, stopped
+        }
         if len(res) > 0 {
             in[res[0].outcome] = 1
         }
@@ -240,9 +338,12 @@ func (lsbn *LargeScaleBrainNetwork) Expose (circuit string, stimulus []float64)
         }
     }
     
-    res := r.Circuit.ExposeTo(ins)
+    res, err := r.Circuit.ExposeToContext(ctx, ins)
+    if err != nil && stopped == nil {
+        stopped = err
+    }
     
-    return ins, res
+    return ins, res, stopped
 }
 
 func (lsbn *LargeScaleBrainNetwork) Correct (circuit string, res []RankedResult, v int, stimulus []float64) {
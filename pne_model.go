@@ -0,0 +1,165 @@
+package main
+
+import (
+    "math"
+)
+
+// NeuronModel is the membrane behaviour of a neuron. Models only hold their
+// parameters; the state they integrate lives on the neuron itself, so a single
+// model can be shared by every neuron of a type. Callers hold the neuron's lock.
+type NeuronModel interface {
+    Name() string
+    // Genesis sets up the membrane of a newly grown neuron.
+    Genesis(n *Neuron)
+    AssumeRestingPotential(n *Neuron)
+    // Excite integrates an excitatory postsynaptic potential arriving at the
+    // simulated time now and reports whether the neuron is going to spike and
+    // how many ms from now it will do so.
+    Excite(n *Neuron, amount float64, now float64) (bool, float64)
+    Inhibit(n *Neuron, amount float64, now float64)
+    // Activate resets the membrane after a spike and, like Excite, reports
+    // whether the neuron spikes again without further input (e.g. in a burst).
+    Activate(n *Neuron, now float64) (bool, float64)
+    Hyperpolarization(n *Neuron, now float64)
+}
+
+// ThresholdModel is the original threshold neuron: potentials add up until
+// they cross ThresholdPotential, optionally leaking toward rest in between.
+type ThresholdModel struct {
+    Params NeuronParams
+}
+
+func (m ThresholdModel) Name() string {
+    return "threshold"
+}
+
+func (m ThresholdModel) Genesis(n *Neuron) {
+    n.ThresholdPotential = m.Params.ThresholdPotential
+    m.AssumeRestingPotential(n)
+}
+
+func (m ThresholdModel) AssumeRestingPotential(n *Neuron) {
+    n.MembranePotential = m.Params.RestingPotential
+    n.InRefractoryPeriod = false
+}
+
+func (m ThresholdModel) Hyperpolarization(n *Neuron, now float64) {
+    n.MembranePotential = m.Params.HyperpolarizedPotential
+    n.InRefractoryPeriod = true
+    n.RefractoryUntil = now + m.Params.RefractoryPeriod
+    n.LastUpdate = now
+    if m.Params.RefractoryPeriod <= 0 && m.Params.MembraneTimeConstant <= 0 {
+        m.AssumeRestingPotential(n)
+    }
+}
+
+// Leak brings the membrane up to date with the simulated time now: it is held
+// hyperpolarised until the refractory period is over and decays toward rest
+// from then on.
+func (m ThresholdModel) Leak(n *Neuron, now float64) {
+    p := m.Params
+    if n.InRefractoryPeriod {
+        if now < n.RefractoryUntil {
+            n.LastUpdate = now
+            return
+        }
+        n.InRefractoryPeriod = false
+        n.LastUpdate = n.RefractoryUntil
+        if p.MembraneTimeConstant <= 0 {
+            m.AssumeRestingPotential(n)
+        }
+    }
+    
+    if p.MembraneTimeConstant > 0 && now > n.LastUpdate {
+        decay := math.Exp(-(now - n.LastUpdate) / p.MembraneTimeConstant)
+        n.MembranePotential = p.RestingPotential + (n.MembranePotential - p.RestingPotential) * decay
+    }
+    n.LastUpdate = now
+}
+
+func (m ThresholdModel) Inhibit(n *Neuron, amount float64, now float64) {
+    m.Leak(n, now)
+    if n.InRefractoryPeriod {
+        return
+    }
+    n.MembranePotential -= amount
+}
+
+func (m ThresholdModel) Excite(n *Neuron, amount float64, now float64) (bool, float64) {
+    m.Leak(n, now)
+    if n.InRefractoryPeriod {
+        return false, 0
+    }
+    n.MembranePotential += amount
+    return n.MembranePotential >= n.ThresholdPotential, 0
+}
+
+func (m ThresholdModel) Activate(n *Neuron, now float64) (bool, float64) {
+    m.Hyperpolarization(n, now)
+    return false, 0
+}
+
+// ModelFor is the model neurons of type t are grown with: whatever Models
+// holds for t, or the threshold model with the circuit's Params otherwise.
+func (circuit *Circuit) ModelFor(t int) NeuronModel {
+    if m, ok := circuit.Models[t]; ok {
+        return m
+    }
+    return ThresholdModel{circuit.Params}
+}
+
+// gain is what a model's SynapticGain multiplies synaptic input by: models
+// whose threshold lies further above rest than the threshold model's need
+// more than its two coincident synapses to fire. 0 leaves input as it is.
+func gain(g float64) float64 {
+    if g == 0 {
+        return 1
+    }
+    return g
+}
+
+// how far ahead (ms) models with spike dynamics look for an upcoming spike
+const spikeHorizon = 50.0
+
+// derivatives of a two-variable membrane model without input: the potential
+// v in mV and its recovery variable u, per ms
+type derivatives func(v float64, u float64) (float64, float64)
+
+// evolve integrates a two-variable model from the neuron's last update up to
+// now. Once v reaches peak it is held there until the spike resets it.
+func evolve(n *Neuron, now float64, step float64, peak float64, f derivatives) {
+    v, u := n.MembranePotential * 100, n.Recovery
+    for t := n.LastUpdate; t < now && v < peak; t += step {
+        dt := math.Min(step, now - t)
+        dv, du := f(v, u)
+        v += dt * dv
+        u += dt * du
+    }
+    if v > peak {
+        v = peak
+    }
+    n.MembranePotential = v / 100
+    n.Recovery = u
+    if now > n.LastUpdate {
+        n.LastUpdate = now
+    }
+}
+
+// upcoming follows a two-variable model from its current state and reports
+// whether, and after how many ms, it reaches peak without further input. The
+// search stops as soon as the membrane heads back down.
+func upcoming(n *Neuron, step float64, peak float64, f derivatives) (bool, float64) {
+    v, u := n.MembranePotential * 100, n.Recovery
+    for t := 0.0; t < spikeHorizon; t += step {
+        if v >= peak {
+            return true, t
+        }
+        dv, du := f(v, u)
+        if dv < 0 {
+            return false, 0
+        }
+        v += step * dv
+        u += step * du
+    }
+    return false, 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+    "testing"
+)
+
+// Every preset perceives stimuli in the topology Neurogenesis grows, whether
+// it drives the deep and mechanical neurons or every neuron.
+func TestModelPresetsPerceive(t *testing.T) {
+    stimuli, _ := numbers(t)
+    presets := map[string]NeuronModel{
+        "regular spiking": IzhikevichRegularSpiking(),
+        "fast spiking": IzhikevichFastSpiking(),
+        "bursting": IzhikevichBursting(),
+        "adaptive exponential": AdaptiveExponential(),
+    }
+    for name, m := range presets {
+        for _, sensory := range []bool{false, true} {
+            models := map[int]NeuronModel{neurontype.Deep: m, neurontype.Mechanical: m}
+            if sensory {
+                models[neurontype.Sensory] = m
+            }
+            c := &Circuit{Models: models}
+            c.Neurogenesis(256, 10)
+            
+            perceived := 0
+            for _, stimulus := range stimuli[:10] {
+                if len(c.ExposeTo(stimulus.GreyScale)) > 0 {
+                    perceived += 1
+                }
+            }
+            if perceived < 5 {
+                t.Errorf("%s (sensory neurons too: %v) perceived %d of 10 stimuli", name, sensory, perceived)
+            }
+        }
+    }
+}
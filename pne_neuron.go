@@ -3,6 +3,7 @@ package main
 import (
     //"time"
     "math"
+    "sync"
 )
 
 type NeuronType struct {
@@ -11,15 +12,50 @@ type NeuronType struct {
 
 var neurontype = NeuronType{-1, 0, 1, 2}
 
+// NeuronParams holds the membrane constants shared by all neurons of a circuit.
+// Potentials are in the same units as stimuli, times are in simulated ms.
+type NeuronParams struct {
+    RestingPotential float64
+    ThresholdPotential float64
+    HyperpolarizedPotential float64
+    // time constant of the leak toward rest; 0 means the membrane doesn't leak
+    MembraneTimeConstant float64
+    // absolute refractory period after a spike; 0 ends it instantly
+    RefractoryPeriod float64
+}
+
+// DefaultNeuronParams are the original constants: no leak and a refractory
+// period that ends as soon as the neuron has fired.
+func DefaultNeuronParams() NeuronParams {
+    return NeuronParams{-0.70, -0.55, -0.90, 0, 0}
+}
+
 type Neuron struct {
     circuit *Circuit
     Index int
     Type int
+    Innovation int
     Axon *axon
+    Model NeuronModel
     MembranePotential float64
     ThresholdPotential float64
     InRefractoryPeriod bool
+    RefractoryUntil float64
+    LastUpdate float64
+    // second state variable of models with adaptation (u, w)
+    Recovery float64
+    LastSpike float64
+    HasSpiked bool
+    // the circuit's exposure LastSpike happened in
+    exposure int
+    // running average of spikes per exposure, see Homeostasis
+    FiringRate float64
+    spikes int
     Dendrites []Dendrite
+    // terminals of other neurons' axons that synapse onto this neuron
+    Afferents []*axonTerminal
+    pending bool
+    mu sync.Mutex
 }
 
 func (n *Neuron) Genesis(index int, t int, circuit *Circuit) {
@@ -33,7 +69,8 @@ func (n *Neuron) Genesis(index int, t int, circuit *Circuit) {
         n.Dendrites = append(n.Dendrites, Dendrite{nil, n})
     }
     
-    n.AssumeRestingPotential()
+    n.Model = n.circuit.ModelFor(t)
+    n.Model.Genesis(n)
 }
 
 func (n *Neuron) GetVacantDendrite() *Dendrite{
@@ -46,60 +83,78 @@ func (n *Neuron) GetVacantDendrite() *Dendrite{
 }
 
 func (n *Neuron) Hyperpolarization() {
-    n.MembranePotential = -0.90
-    n.InRefractoryPeriod = true
-    //time.Sleep(1 * time.Millisecond)
-    n.AssumeRestingPotential()
+    n.Model.Hyperpolarization(n, n.circuit.Now())
 }
 
 func (n *Neuron) AssumeRestingPotential() {
-    n.MembranePotential = -0.70
-    n.ThresholdPotential = -0.55
-    n.InRefractoryPeriod = false
+    n.Model.AssumeRestingPotential(n)
 }
 
-func (n *Neuron) Inhibit() {
-    if (*n).InRefractoryPeriod {
-        return 
-    }
+func (n *Neuron) Inhibit(in ... float64) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
     
-    n.MembranePotential -= 0.075
+    if len(in) > 0 {
+        n.Model.Inhibit(n, in[0], n.circuit.Now())
+    } else {
+        n.Model.Inhibit(n, defaultSynapticWeight, n.circuit.Now())
+    }
+    n.sample()
 }
 
-func (n *Neuron) Excite(in ... float64) {
-    if (*n).InRefractoryPeriod {
-        return
+// sample hands the membrane to the circuit's Recorder; callers hold the lock.
+func (n *Neuron) sample() {
+    if n.circuit.Recorder != nil {
+        n.circuit.Recorder.sample(n, n.circuit.Now())
     }
-    
+}
+
+func (n *Neuron) Excite(in ... float64) {
+    n.mu.Lock()
+    var fires bool
+    var latency float64
     if len(in) > 0 {
-        n.MembranePotential += in[0]
+        fires, latency = n.Model.Excite(n, in[0], n.circuit.Now())
     } else {
-        n.MembranePotential += 0.075
+        fires, latency = n.Model.Excite(n, defaultSynapticWeight, n.circuit.Now())
     }
-        
-    if n.MembranePotential >= n.ThresholdPotential {
-        go n.Activate()
+    n.sample()
+    n.mu.Unlock()
+    
+    if fires {
+        n.circuit.Fire(n, latency)
     }
 }
 
 func (n *Neuron) Activate() {
+    if !n.circuit.spiked() {
+        return
+    }
     defer func() {
-        n.Hyperpolarization()
+        n.mu.Lock()
+        again, latency := n.Model.Activate(n, n.circuit.Now())
+        n.sample()
+        n.mu.Unlock()
+        if again {
+            n.circuit.Fire(n, latency)
+        }
     }()
+    if n.circuit.Recorder != nil {
+        n.circuit.Recorder.spike(n, n.circuit.Now())
+    }
+    
+    // weights may change under plasticity while the spike is sent on
+    n.circuit.plasticity.Lock()
+    defer n.circuit.plasticity.Unlock()
+    n.circuit.Learn(n, n.circuit.Now())
     
     if n.Type == neurontype.Mechanical {
         inilen := int(math.Ceil((float64(n.circuit.In) - float64(n.circuit.Out)) / float64(2))) + n.circuit.Out + n.circuit.In + n.circuit.Out
         out := n.Index - n.circuit.In - (inilen - n.circuit.In - n.circuit.Out)
-        n.circuit.Results = append(n.circuit.Results, Percept{out})
+        n.circuit.Perceive(Percept{out})
     } else {
         for i := 0; i < len(n.Axon.Terminals); i++ {
-            if n.Axon.Terminals[i].To != nil {
-                if n.Axon.Terminals[i].SynapseIsExcitatory {
-                    go n.Axon.Terminals[i].To.PartOf.Excite()
-                } else {
-                    go n.Axon.Terminals[i].To.PartOf.Inhibit()
-                }
-            }
+            n.circuit.Transmit(n.Axon.Terminals[i])
         }
     }
 }
\ No newline at end of file
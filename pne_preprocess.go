@@ -0,0 +1,147 @@
+package main
+
+import (
+    "image"
+    "math"
+)
+
+type CropType struct {
+    None, Center, BoundingBox int
+}
+
+var croptype = CropType{0, 1, 2}
+
+// Frame is a greyscale image in grey levels from 0 to 255, row by row.
+type Frame struct {
+    Width int
+    Height int
+    Pix []float64
+}
+
+// FrameOf reads the luminance of every pixel of img.
+func FrameOf(img image.Image) Frame {
+    bounds := img.Bounds()
+    f := Frame{bounds.Dx(), bounds.Dy(), make([]float64, 0, bounds.Dx() * bounds.Dy())}
+    for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+        for x := bounds.Min.X; x < bounds.Max.X; x++ {
+            f.Pix = append(f.Pix, float64(PixelToGS(RGBAToPixel(img.At(x, y).RGBA()))))
+        }
+    }
+    return f
+}
+
+func (f Frame) crop(x0 int, y0 int, w int, h int) Frame {
+    c := Frame{w, h, make([]float64, w * h)}
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            if sx, sy := x0 + x, y0 + y; sx >= 0 && sx < f.Width && sy >= 0 && sy < f.Height {
+                c.Pix[y * w + x] = f.Pix[sy * f.Width + sx]
+            }
+        }
+    }
+    return c
+}
+
+// Preprocessing turns images into stimuli. The steps are taken in this order:
+//
+//   - Invert turns dark ink on a light background into bright ink, as
+//     stimuli are the stronger the more ink there is
+//   - Crop cuts out the CropWidth x CropHeight centre of the image, or the
+//     smallest square around its ink (anything brighter than a tenth of the
+//     brightest pixel)
+//   - Width and Height, if set, resize the image
+//   - Normalize stretches the contrast so the image spans all grey levels
+//   - Threshold, if set, turns every pixel at or above that fraction of full
+//     brightness into ink and the rest into background
+//   - Scale is what full brightness becomes in the stimulus
+type Preprocessing struct {
+    Invert bool
+    Crop int
+    CropWidth int
+    CropHeight int
+    Width int
+    Height int
+    Normalize bool
+    Threshold float64
+    Scale float64
+}
+
+// DefaultPreprocessing is what images always went through: inverted and
+// scaled into 0..0.2, see GSToGR.
+func DefaultPreprocessing() Preprocessing {
+    return Preprocessing{Invert: true, Scale: 0.2}
+}
+
+// Process takes f through the steps and returns the stimulus.
+func (p Preprocessing) Process(f Frame) []float64 {
+    f = Frame{f.Width, f.Height, append([]float64(nil), f.Pix...)}
+
+    if p.Invert {
+        for i, v := range f.Pix {
+            f.Pix[i] = 255 - v
+        }
+    }
+
+    switch p.Crop {
+    case croptype.Center:
+        w, h := p.CropWidth, p.CropHeight
+        if w <= 0 || w > f.Width {
+            w = f.Width
+        }
+        if h <= 0 || h > f.Height {
+            h = f.Height
+        }
+        f = f.crop((f.Width - w) / 2, (f.Height - h) / 2, w, h)
+    case croptype.BoundingBox:
+        brightest := 0.0
+        for _, v := range f.Pix {
+            brightest = math.Max(brightest, v)
+        }
+        x0, y0, x1, y1 := f.Width, f.Height, -1, -1
+        for y := 0; y < f.Height; y++ {
+            for x := 0; x < f.Width; x++ {
+                if brightest > 0 && f.Pix[y * f.Width + x] > brightest / 10 {
+                    x0, y0 = int(math.Min(float64(x0), float64(x))), int(math.Min(float64(y0), float64(y)))
+                    x1, y1 = int(math.Max(float64(x1), float64(x))), int(math.Max(float64(y1), float64(y)))
+                }
+            }
+        }
+        if x1 >= 0 {
+            // keep the aspect ratio of the ink by cropping a square around it
+            side := int(math.Max(float64(x1 - x0 + 1), float64(y1 - y0 + 1)))
+            f = f.crop(x0 - (side - (x1 - x0 + 1)) / 2, y0 - (side - (y1 - y0 + 1)) / 2, side, side)
+        }
+    }
+
+    if p.Width > 0 && p.Height > 0 && (p.Width != f.Width || p.Height != f.Height) {
+        f = Frame{p.Width, p.Height, resize(f.Pix, f.Width, f.Height, p.Width, p.Height)}
+    }
+
+    if p.Normalize {
+        lo, hi := math.Inf(1), math.Inf(-1)
+        for _, v := range f.Pix {
+            lo, hi = math.Min(lo, v), math.Max(hi, v)
+        }
+        if hi > lo {
+            for i, v := range f.Pix {
+                f.Pix[i] = (v - lo) / (hi - lo) * 255
+            }
+        }
+    }
+
+    if p.Threshold > 0 {
+        for i, v := range f.Pix {
+            if v >= p.Threshold * 255 {
+                f.Pix[i] = 255
+            } else {
+                f.Pix[i] = 0
+            }
+        }
+    }
+
+    stimulus := make([]float64, len(f.Pix))
+    for i, v := range f.Pix {
+        stimulus[i] = v / 255 * p.Scale
+    }
+    return stimulus
+}
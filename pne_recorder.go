@@ -0,0 +1,253 @@
+package main
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "sync"
+)
+
+// SpikeRecord is one activation of a neuron. Stimulus is the exposure it
+// happened in, counting from 0 since the recorder was attached, i.e. the
+// position of the stimulus in the order they were presented. Seq orders the
+// spikes of a recording, which matters under the goroutine engine where times
+// are only as precise as the wall clock.
+type SpikeRecord struct {
+    Stimulus int
+    Seq int
+    Time float64
+    Neuron int
+    Type int
+}
+
+// MembraneSample is the membrane potential of a watched neuron right after it
+// changed.
+type MembraneSample struct {
+    Stimulus int
+    Time float64
+    Neuron int
+    Potential float64
+}
+
+// Recorder records every spike of the circuit it is attached to (as Recorder)
+// and samples the membranes of the neurons it watches. Labels holds the tag of
+// every exposure, by Stimulus, as given to Label. Its writers may run while
+// the circuit is exposed; read Spikes, Samples and Labels directly only
+// between exposures.
+type Recorder struct {
+    Spikes []SpikeRecord
+    Samples []MembraneSample
+    Labels []string
+    watch map[int]bool
+    exposures int
+    label string
+    mu sync.Mutex
+}
+
+// NewRecorder records spikes and samples the membrane potential of the
+// neurons with the given indexes.
+func NewRecorder(watch ... int) *Recorder {
+    r := &Recorder{watch: make(map[int]bool, len(watch))}
+    for _, i := range watch {
+        r.watch[i] = true
+    }
+    return r
+}
+
+// Reset forgets everything recorded so far; exposures are counted afresh.
+func (r *Recorder) Reset() {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    
+    r.Spikes = nil
+    r.Samples = nil
+    r.Labels = nil
+    r.exposures = 0
+    r.label = ""
+}
+
+// Label tags the next exposure, e.g. with the label or path of the stimulus
+// about to be presented. Exposures that weren't tagged get "".
+func (r *Recorder) Label(label string) {
+    r.mu.Lock()
+    r.label = label
+    r.mu.Unlock()
+}
+
+func (r *Recorder) expose() {
+    r.mu.Lock()
+    r.exposures += 1
+    r.Labels = append(r.Labels, r.label)
+    r.label = ""
+    r.mu.Unlock()
+}
+
+// labelOf is the tag of an exposure; callers hold the lock.
+func (r *Recorder) labelOf(stimulus int) string {
+    if stimulus < 0 || stimulus >= len(r.Labels) {
+        return ""
+    }
+    return r.Labels[stimulus]
+}
+
+// csvField quotes s if it holds anything that would break a CSV line.
+func csvField(s string) string {
+    if !strings.ContainsAny(s, ",\"\r\n") {
+        return s
+    }
+    return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func (r *Recorder) spike(n *Neuron, now float64) {
+    r.mu.Lock()
+    r.Spikes = append(r.Spikes, SpikeRecord{r.exposures - 1, len(r.Spikes), now, n.Index, n.Type})
+    r.mu.Unlock()
+}
+
+// sample is called with the neuron's lock held.
+func (r *Recorder) sample(n *Neuron, now float64) {
+    r.mu.Lock()
+    if r.watch[n.Index] {
+        r.Samples = append(r.Samples, MembraneSample{r.exposures - 1, now, n.Index, n.MembranePotential})
+    }
+    r.mu.Unlock()
+}
+
+// WriteCSV writes the spikes, one per line, for raster plots.
+func (r *Recorder) WriteCSV(w io.Writer) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    
+    b := bufio.NewWriter(w)
+    fmt.Fprintf(b, "stimulus,label,seq,time,neuron,type\n")
+    for _, s := range r.Spikes {
+        fmt.Fprintf(b, "%d,%s,%d,%g,%d,%s\n", s.Stimulus, csvField(r.labelOf(s.Stimulus)), s.Seq, s.Time, s.Neuron, typeName(s.Type))
+    }
+    return b.Flush()
+}
+
+// WriteSamplesCSV writes the membrane samples, one per line.
+func (r *Recorder) WriteSamplesCSV(w io.Writer) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    
+    b := bufio.NewWriter(w)
+    fmt.Fprintf(b, "stimulus,label,time,neuron,potential\n")
+    for _, s := range r.Samples {
+        fmt.Fprintf(b, "%d,%s,%g,%d,%g\n", s.Stimulus, csvField(r.labelOf(s.Stimulus)), s.Time, s.Neuron, s.Potential)
+    }
+    return b.Flush()
+}
+
+// magic number and version of the binary recording format
+const (
+    recordingMagic = "PNER"
+    recordingVersion = 2
+)
+
+var ErrRecordingFormat = errors.New("pne: not a spike recording")
+
+// binary layouts of the records, little endian
+type spikeRecord struct {
+    Stimulus int32
+    Seq uint32
+    Time float64
+    Neuron int32
+    Type int8
+}
+
+type membraneSample struct {
+    Stimulus int32
+    Time float64
+    Neuron int32
+    Potential float64
+}
+
+// WriteBinary writes spikes and samples in a compact binary format: the magic
+// "PNER", a version byte, then the number of exposure labels (uint32) followed
+// by the labels (length uint32 and the bytes), the number of spikes followed
+// by the spikes (stimulus int32, seq uint32, time float64, neuron int32, type
+// int8) and the number of samples followed by the samples (stimulus int32,
+// time float64, neuron int32, potential float64), all little endian. Version 1
+// had no labels.
+func (r *Recorder) WriteBinary(w io.Writer) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    
+    b := bufio.NewWriter(w)
+    b.WriteString(recordingMagic)
+    b.WriteByte(recordingVersion)
+    
+    binary.Write(b, binary.LittleEndian, uint32(len(r.Labels)))
+    for _, l := range r.Labels {
+        binary.Write(b, binary.LittleEndian, uint32(len(l)))
+        b.WriteString(l)
+    }
+    binary.Write(b, binary.LittleEndian, uint32(len(r.Spikes)))
+    for _, s := range r.Spikes {
+        binary.Write(b, binary.LittleEndian, spikeRecord{int32(s.Stimulus), uint32(s.Seq), s.Time, int32(s.Neuron), int8(s.Type)})
+    }
+    binary.Write(b, binary.LittleEndian, uint32(len(r.Samples)))
+    for _, s := range r.Samples {
+        binary.Write(b, binary.LittleEndian, membraneSample{int32(s.Stimulus), s.Time, int32(s.Neuron), s.Potential})
+    }
+    
+    return b.Flush()
+}
+
+// ReadRecording reads spikes and samples written by WriteBinary.
+func ReadRecording(rd io.Reader) (*Recorder, error) {
+    b := bufio.NewReader(rd)
+    head := make([]byte, len(recordingMagic) + 1)
+    if _, err := io.ReadFull(b, head); err != nil || string(head[:len(recordingMagic)]) != recordingMagic {
+        return nil, ErrRecordingFormat
+    }
+    version := head[len(recordingMagic)]
+    if version < 1 || version > recordingVersion {
+        return nil, fmt.Errorf("%w: version %d is not supported", ErrRecordingFormat, version)
+    }
+    
+    r := NewRecorder()
+    var count uint32
+    if version >= 2 {
+        if err := binary.Read(b, binary.LittleEndian, &count); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+        }
+        for i := uint32(0); i < count; i++ {
+            var n uint32
+            if err := binary.Read(b, binary.LittleEndian, &n); err != nil {
+                return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+            }
+            l := make([]byte, n)
+            if _, err := io.ReadFull(b, l); err != nil {
+                return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+            }
+            r.Labels = append(r.Labels, string(l))
+        }
+    }
+    if err := binary.Read(b, binary.LittleEndian, &count); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+    }
+    for i := uint32(0); i < count; i++ {
+        var s spikeRecord
+        if err := binary.Read(b, binary.LittleEndian, &s); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+        }
+        r.Spikes = append(r.Spikes, SpikeRecord{int(s.Stimulus), int(s.Seq), s.Time, int(s.Neuron), int(s.Type)})
+    }
+    if err := binary.Read(b, binary.LittleEndian, &count); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+    }
+    for i := uint32(0); i < count; i++ {
+        var s membraneSample
+        if err := binary.Read(b, binary.LittleEndian, &s); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrRecordingFormat, err)
+        }
+        r.Samples = append(r.Samples, MembraneSample{int(s.Stimulus), s.Time, int(s.Neuron), s.Potential})
+    }
+    
+    return r, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+// Exposures are tagged with what was given to Label before them, and the tags
+// survive the binary format.
+func TestRecordingKeepsLabels(t *testing.T) {
+    stimuli, _ := numbers(t)
+    c := &Circuit{}
+    c.Neurogenesis(256, 10)
+    c.Recorder = NewRecorder(300)
+    for i, stimulus := range stimuli[:3] {
+        if i != 1 {
+            c.Recorder.Label(stimulus.Path)
+        }
+        c.ExposeTo(stimulus.GreyScale)
+    }
+    if want := []string{stimuli[0].Path, "", stimuli[2].Path}; !reflect.DeepEqual(c.Recorder.Labels, want) {
+        t.Fatalf("labels %q, want %q", c.Recorder.Labels, want)
+    }
+
+    var buf bytes.Buffer
+    if err := c.Recorder.WriteBinary(&buf); err != nil {
+        t.Fatal(err)
+    }
+    r, err := ReadRecording(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(r.Labels, c.Recorder.Labels) || !reflect.DeepEqual(r.Spikes, c.Recorder.Spikes) || !reflect.DeepEqual(r.Samples, c.Recorder.Samples) {
+        t.Fatal("recording changed on its way through the binary format")
+    }
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+    "math"
+)
+
+// RewardModulation is a three-factor learning rule: during an exposure every
+// excitatory synapse whose presynaptic spike preceded a postsynaptic one builds
+// up an eligibility trace, and Reinforce turns those traces into weight changes
+// in proportion to a reward that arrives afterwards.
+type RewardModulation struct {
+    LearningRate float64
+    // time constant (simulated ms) of how much a pre/post pairing counts
+    TraceWindow float64
+    MaxWeight float64
+}
+
+func DefaultRewardModulation() RewardModulation {
+    return RewardModulation{0.01, 20, 2 * defaultSynapticWeight}
+}
+
+// trace marks the synapses onto n that helped it fire at the simulated time
+// now. Callers hold the circuit's plasticity lock.
+func (circuit *Circuit) trace(n *Neuron, now float64) {
+    p := circuit.Reward
+    for _, at := range n.Afferents {
+        pre := at.From.From
+        if !at.SynapseIsExcitatory || !circuit.spikedThisExposure(pre) {
+            continue
+        }
+        if at.Eligibility == 0 {
+            circuit.eligible = append(circuit.eligible, at)
+        }
+        at.Eligibility += math.Exp(-(now - pre.LastSpike) / p.TraceWindow)
+    }
+}
+
+// ClearEligibility forgets the traces of the previous exposure.
+func (circuit *Circuit) ClearEligibility() {
+    for _, at := range circuit.eligible {
+        at.Eligibility = 0
+    }
+    circuit.eligible = nil
+}
+
+// MechanicalFor is the mechanical neuron whose percepts carry outcome.
+func (circuit *Circuit) MechanicalFor(outcome int) *Neuron {
+    deep := int(math.Ceil((float64(circuit.In) - float64(circuit.Out)) / float64(2))) + circuit.Out
+    return circuit.Cluster[deep + circuit.In + outcome]
+}
+
+// Reinforce rewards (or, for a negative reward, punishes) the synapses that
+// led to the winning outcome of the last exposure: starting at the winning
+// mechanical neuron, every eligible synapse along the way back is adjusted.
+// Eligibility is only recorded while Reward is set.
+func (circuit *Circuit) Reinforce(results []RankedResult, reward float64) {
+    p := circuit.Reward
+    if p == nil || len(results) == 0 {
+        return
+    }
+    
+    winner := circuit.MechanicalFor(results[0].outcome)
+    visited := map[*Neuron]bool{winner: true}
+    queue := []*Neuron{winner}
+    for len(queue) > 0 {
+        post := queue[0]
+        queue = queue[1:]
+        for _, at := range post.Afferents {
+            if at.Eligibility <= 0 {
+                continue
+            }
+            at.Adjust(p.LearningRate * reward * at.Eligibility)
+            if at.Weight > p.MaxWeight {
+                at.Weight = p.MaxWeight
+            }
+            if pre := at.From.From; !visited[pre] {
+                visited[pre] = true
+                queue = append(queue, pre)
+            }
+        }
+    }
+}
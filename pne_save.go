@@ -0,0 +1,266 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "sort"
+)
+
+// version of the on-disk format written by Save; LoadCircuit reads this
+// version and every one before it. Version 2 added the state of Rand, version
+// 3 the circuit's labels.
+const circuitFormatVersion = 3
+
+var ErrCircuitFormat = errors.New("pne: not a saved circuit")
+
+// savedCircuit is the on-disk form of a circuit: its genome plus everything
+// that isn't structure, i.e. the learning rules, the engine settings and the
+// state of every membrane.
+type savedCircuit struct {
+    Format string
+    Version int
+    Genome *Genome
+    Innovations *Innovations
+    Labels []string
+    STDP *STDP
+    Reward *RewardModulation
+    Homeostasis *Homeostasis
+    Seed int64
+    // nil if the circuit never drew a random number
+    Rand *randState
+    Engine int
+    SpikeBudget int
+    TimeBudget float64
+    Now float64
+    Neurons []membraneState
+}
+
+type randState struct {
+    Seed int64
+    Draws uint64
+}
+
+type membraneState struct {
+    MembranePotential float64
+    InRefractoryPeriod bool
+    RefractoryUntil float64
+    LastUpdate float64
+    Recovery float64
+    LastSpike float64
+    HasSpiked bool
+    FiringRate float64
+}
+
+func (circuit *Circuit) saved() (*savedCircuit, error) {
+    g, err := circuit.ToGenome()
+    if err != nil {
+        return nil, err
+    }
+    s := &savedCircuit{
+        Format: "pne-circuit",
+        Version: circuitFormatVersion,
+        Genome: g,
+        Innovations: circuit.Innovations,
+        STDP: circuit.STDP,
+        Reward: circuit.Reward,
+        Homeostasis: circuit.Homeostasis,
+        Seed: circuit.Seed,
+        Engine: circuit.Engine,
+        SpikeBudget: circuit.SpikeBudget,
+        TimeBudget: circuit.TimeBudget,
+        Now: circuit.Scheduler().Now,
+    }
+    if circuit.Labels != nil {
+        s.Labels = circuit.Labels.Names
+    }
+    if circuit.source != nil {
+        s.Rand = &randState{circuit.source.seed, circuit.source.draws}
+    }
+    for _, n := range circuit.Cluster {
+        s.Neurons = append(s.Neurons, membraneState{n.MembranePotential, n.InRefractoryPeriod, n.RefractoryUntil, n.LastUpdate, n.Recovery, n.LastSpike, n.HasSpiked, n.FiringRate})
+    }
+    return s, nil
+}
+
+func (s *savedCircuit) circuit() (*Circuit, error) {
+    if s.Format != "pne-circuit" || s.Genome == nil {
+        return nil, ErrCircuitFormat
+    }
+    if s.Version < 1 || s.Version > circuitFormatVersion {
+        return nil, fmt.Errorf("%w: format version %d is not supported", ErrCircuitFormat, s.Version)
+    }
+    if len(s.Neurons) != len(s.Genome.Neurons) {
+        return nil, fmt.Errorf("%w: %d membranes for %d neurons", ErrCircuitFormat, len(s.Neurons), len(s.Genome.Neurons))
+    }
+    
+    circuit, err := NewCircuitFromGenome(s.Genome)
+    if err != nil {
+        return nil, err
+    }
+    if s.Innovations != nil {
+        circuit.Innovations = s.Innovations
+    }
+    if s.Labels != nil {
+        circuit.Labels = &LabelSet{s.Labels}
+    }
+    circuit.STDP = s.STDP
+    circuit.Reward = s.Reward
+    circuit.Homeostasis = s.Homeostasis
+    circuit.Seed = s.Seed
+    if s.Rand != nil {
+        circuit.source = newCountingSource(s.Rand.Seed, s.Rand.Draws)
+        circuit.rng = rand.New(circuit.source)
+    }
+    circuit.Engine = s.Engine
+    circuit.SpikeBudget = s.SpikeBudget
+    circuit.TimeBudget = s.TimeBudget
+    circuit.Scheduler().Now = s.Now
+    for i, m := range s.Neurons {
+        n := circuit.Cluster[i]
+        n.MembranePotential = m.MembranePotential
+        n.InRefractoryPeriod = m.InRefractoryPeriod
+        n.RefractoryUntil = m.RefractoryUntil
+        n.LastUpdate = m.LastUpdate
+        n.Recovery = m.Recovery
+        n.LastSpike = m.LastSpike
+        n.HasSpiked = m.HasSpiked
+        n.FiringRate = m.FiringRate
+    }
+    return circuit, nil
+}
+
+// Save writes the circuit to w: its neurons and terminals, the learning rules
+// and settings it was given and the state of every membrane, so that
+// LoadCircuit picks up where it left off. Exposures must not be running.
+func (circuit *Circuit) Save(w io.Writer) error {
+    s, err := circuit.saved()
+    if err != nil {
+        return err
+    }
+    return json.NewEncoder(w).Encode(s)
+}
+
+// LoadCircuit reads a circuit written by Save and reconnects all its neurons.
+// Rand carries on where it was when the circuit was saved.
+func LoadCircuit(r io.Reader) (*Circuit, error) {
+    s := &savedCircuit{}
+    if err := json.NewDecoder(r).Decode(s); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrCircuitFormat, err)
+    }
+    return s.circuit()
+}
+
+// version of the archive format written by LargeScaleBrainNetwork.Save
+const networkFormatVersion = 1
+
+var ErrNetworkFormat = errors.New("pne: not a saved large scale brain network")
+
+// savedNetwork is the on-disk form of a whole LSBN. Circuits grown on top of
+// each other share their raw stimuli, so these are kept once in Stimuli and
+// referred to by position.
+type savedNetwork struct {
+    Format string
+    Version int
+    STDP *STDP
+    Stimuli [][]float64
+    Circuits []savedLSBNCircuit
+}
+
+type savedLSBNCircuit struct {
+    Identifier string
+    Circuit *savedCircuit
+    Data []LSBNChunk
+    StimLength int
+    ChunkLength int
+    ChunkBeta float64
+    // position in Stimuli, or -1 for none
+    RawStimuli int
+    Types int
+    ConnectsTo string
+}
+
+// Save writes every circuit of the network to w as one archive, along with
+// the chunks and chunk types each learned, so that a trained circuit can have
+// new circuits grown on top of it in a later run.
+func (lsbn *LargeScaleBrainNetwork) Save(w io.Writer) error {
+    s := &savedNetwork{Format: "pne-lsbn", Version: networkFormatVersion, STDP: lsbn.STDP}
+    
+    identifiers := make([]string, 0, len(lsbn.Circuits))
+    for identifier := range lsbn.Circuits {
+        identifiers = append(identifiers, identifier)
+    }
+    sort.Strings(identifiers)
+    
+    stimuli := make(map[*[]float64]int)
+    for _, identifier := range identifiers {
+        lc := lsbn.Circuits[identifier]
+        sc := savedLSBNCircuit{lc.Identifier, nil, lc.Data, lc.StimLength, lc.ChunkLength, lc.ChunkBeta, -1, lc.Types, lc.ConnectsTo}
+        if lc.Circuit != nil {
+            c, err := lc.Circuit.saved()
+            if err != nil {
+                return fmt.Errorf("pne: saving circuit %q: %w", identifier, err)
+            }
+            sc.Circuit = c
+        }
+        if lc.RawStimuli != nil {
+            at, ok := stimuli[lc.RawStimuli]
+            if !ok {
+                at = len(s.Stimuli)
+                stimuli[lc.RawStimuli] = at
+                s.Stimuli = append(s.Stimuli, *lc.RawStimuli)
+            }
+            sc.RawStimuli = at
+        }
+        s.Circuits = append(s.Circuits, sc)
+    }
+    
+    return json.NewEncoder(w).Encode(s)
+}
+
+// LoadLargeScaleBrainNetwork reads a network written by Save.
+func LoadLargeScaleBrainNetwork(r io.Reader) (*LargeScaleBrainNetwork, error) {
+    s := &savedNetwork{}
+    if err := json.NewDecoder(r).Decode(s); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrNetworkFormat, err)
+    }
+    if s.Format != "pne-lsbn" {
+        return nil, ErrNetworkFormat
+    }
+    if s.Version < 1 || s.Version > networkFormatVersion {
+        return nil, fmt.Errorf("%w: format version %d is not supported", ErrNetworkFormat, s.Version)
+    }
+    
+    lsbn := &LargeScaleBrainNetwork{STDP: s.STDP}
+    lsbn.ForceGenesis()
+    stimuli := make([]*[]float64, len(s.Stimuli))
+    for i := range s.Stimuli {
+        stimuli[i] = &s.Stimuli[i]
+    }
+    for _, sc := range s.Circuits {
+        lc := &LSBNCircuit{sc.Identifier, nil, sc.Data, sc.StimLength, sc.ChunkLength, sc.ChunkBeta, nil, sc.Types, sc.ConnectsTo}
+        if sc.Circuit != nil {
+            c, err := sc.Circuit.circuit()
+            if err != nil {
+                return nil, fmt.Errorf("pne: loading circuit %q: %w", sc.Identifier, err)
+            }
+            lc.Circuit = c
+        }
+        if sc.RawStimuli >= len(stimuli) {
+            return nil, fmt.Errorf("%w: circuit %q refers to stimuli it doesn't have", ErrNetworkFormat, sc.Identifier)
+        }
+        if sc.RawStimuli >= 0 {
+            lc.RawStimuli = stimuli[sc.RawStimuli]
+        }
+        lsbn.Circuits[sc.Identifier] = lc
+    }
+    for _, lc := range lsbn.Circuits {
+        if _, ok := lsbn.Circuits[lc.ConnectsTo]; lc.ConnectsTo != "" && !ok {
+            return nil, fmt.Errorf("%w: circuit %q connects to missing circuit %q", ErrNetworkFormat, lc.Identifier, lc.ConnectsTo)
+        }
+    }
+    
+    return lsbn, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// A loaded circuit is the saved one: same structure, back-pointers rebuilt,
+// and it goes on perceiving exactly as the original does.
+func TestSaveLoadRoundTrip(t *testing.T) {
+    stimuli, labels := numbers(t)
+    c := &Circuit{Labels: labels}
+    c.Neurogenesis(256, 10, NeuronParams{-0.70, -0.55, -0.90, 20, 2})
+    h := DefaultHomeostasis()
+    c.Homeostasis = &h
+    train(t, c, stimuli[:60], labels, 1)
+
+    var buf bytes.Buffer
+    if err := c.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+    d, err := LoadCircuit(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    g, _ := c.ToGenome()
+    e, _ := d.ToGenome()
+    if !reflect.DeepEqual(g, e) {
+        t.Fatal("loaded circuit differs from the saved one")
+    }
+    if !reflect.DeepEqual(c.Labels, d.Labels) {
+        t.Fatalf("labels %v were loaded as %v", c.Labels, d.Labels)
+    }
+    for _, n := range d.Cluster {
+        if n.Axon.From != n {
+            t.Fatalf("axon of neuron %d isn't its own", n.Index)
+        }
+        for _, at := range n.Axon.Terminals {
+            if at.From != n.Axon {
+                t.Fatalf("terminal %d of neuron %d isn't on its axon", at.Innovation, n.Index)
+            }
+            if at.To == nil {
+                continue
+            }
+            post := at.To.PartOf
+            if post != d.Cluster[post.Index] || !hasAfferent(post, at) {
+                t.Fatalf("terminal %d of neuron %d isn't wired to its target", at.Innovation, n.Index)
+            }
+        }
+    }
+
+    for _, stimulus := range stimuli[60:90] {
+        if a, b := c.ExposeTo(stimulus.GreyScale), d.ExposeTo(stimulus.GreyScale); !reflect.DeepEqual(a, b) {
+            t.Fatalf("%s: loaded circuit perceived %v instead of %v", stimulus.Path, b, a)
+        }
+    }
+}
+
+func hasAfferent(n *Neuron, at *axonTerminal) bool {
+    for _, a := range n.Afferents {
+        if a == at {
+            return true
+        }
+    }
+    return false
+}
+
+func TestLoadRejectsUnknownVersion(t *testing.T) {
+    _, err := LoadCircuit(strings.NewReader(`{"Format":"pne-circuit","Version":99,"Genome":{}}`))
+    if !errors.Is(err, ErrCircuitFormat) {
+        t.Fatalf("got %v, want ErrCircuitFormat", err)
+    }
+}
+
+// A loaded network is the saved one: circuits grown on top of each other still
+// share their raw stimuli, chunks keep their peaks, and new circuits can be
+// grown on the loaded ones.
+func TestLSBNSaveLoadRoundTrip(t *testing.T) {
+    stimuli, labels := numbers(t)
+    var raw []float64
+    for _, s := range stimuli[:8] {
+        raw = append(raw, s.GreyScale...)
+    }
+    lsbn := &LargeScaleBrainNetwork{}
+    if _, _, ok := lsbn.GrowCircuit("shapes", 64, 0.6, 256, raw, false, 0); !ok {
+        t.Fatal("can't chunk the stimuli")
+    }
+    lsbn.Grow("numbers", "shapes", labels.Len())
+    for _, stimulus := range stimuli[:20] {
+        outcome, _ := labels.Outcome(stimulus.Type)
+        if stim, res := lsbn.Expose("numbers", stimulus.GreyScale); len(res) > 0 {
+            lsbn.Correct("numbers", res, outcome, stim)
+        }
+    }
+
+    var buf bytes.Buffer
+    if err := lsbn.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := LoadLargeScaleBrainNetwork(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if len(loaded.Circuits) != len(lsbn.Circuits) {
+        t.Fatalf("loaded %d circuits, saved %d", len(loaded.Circuits), len(lsbn.Circuits))
+    }
+    for identifier, lc := range lsbn.Circuits {
+        ld := loaded.Circuits[identifier]
+        if ld == nil {
+            t.Fatalf("circuit %q wasn't loaded", identifier)
+        }
+        if ld.ConnectsTo != lc.ConnectsTo || ld.StimLength != lc.StimLength || ld.ChunkLength != lc.ChunkLength || ld.Types != lc.Types {
+            t.Fatalf("circuit %q was loaded as %+v", identifier, ld)
+        }
+        if !reflect.DeepEqual(ld.Data, lc.Data) {
+            t.Fatalf("chunks of circuit %q differ", identifier)
+        }
+        g, _ := lc.Circuit.ToGenome()
+        e, _ := ld.Circuit.ToGenome()
+        if !reflect.DeepEqual(g, e) {
+            t.Fatalf("circuit %q differs from the saved one", identifier)
+        }
+    }
+    if loaded.Circuits["shapes"].Data[0].PeakMap == nil {
+        t.Fatal("chunks lost their peaks")
+    }
+    shapes, top := loaded.Circuits["shapes"], loaded.Circuits["numbers"]
+    if shapes.RawStimuli != top.RawStimuli || !reflect.DeepEqual(*shapes.RawStimuli, raw) {
+        t.Fatal("raw stimuli aren't shared as saved")
+    }
+
+    for _, stimulus := range stimuli[20:30] {
+        _, a := lsbn.Expose("numbers", stimulus.GreyScale)
+        if _, b := loaded.Expose("numbers", stimulus.GreyScale); !reflect.DeepEqual(a, b) {
+            t.Fatalf("%s: loaded network perceived %v instead of %v", stimulus.Path, b, a)
+        }
+    }
+
+    loaded.Grow("more", "shapes", labels.Len())
+    more := loaded.Circuits["more"]
+    if more.RawStimuli != shapes.RawStimuli || more.StimLength != shapes.Types * (256 / 64) {
+        t.Fatalf("circuit grown on the loaded network is %+v", more)
+    }
+    if _, _, err := loaded.ExposeContext(context.Background(), "more", stimuli[0].GreyScale); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+    "container/heap"
+    "context"
+    "sync/atomic"
+    "time"
+)
+
+type EngineType struct {
+    Event, Goroutine int
+}
+
+// the event engine is the zero value so that circuits are reproducible by default
+var enginetype = EngineType{0, 1}
+
+type EventType struct {
+    Activate, Excite, Inhibit int
+}
+
+var eventtype = EventType{0, 1, 2}
+
+// simulated time (ms) a spike needs to cross a synapse unless the circuit's
+// Delays say otherwise
+const synapticDelay = 1.0
+
+type spikeEvent struct {
+    Time float64
+    Neuron *Neuron
+    Type int
+    In []float64
+    seq int
+}
+
+type spikeQueue []*spikeEvent
+
+func (q spikeQueue) Len() int {
+    return len(q)
+}
+
+func (q spikeQueue) Less(i, j int) bool {
+    if q[i].Time != q[j].Time {
+        return q[i].Time < q[j].Time
+    }
+    if q[i].Neuron.Index != q[j].Neuron.Index {
+        return q[i].Neuron.Index < q[j].Neuron.Index
+    }
+    // a neuron that crossed threshold fires before it integrates anything else
+    if q[i].Type != q[j].Type {
+        return q[i].Type < q[j].Type
+    }
+    return q[i].seq < q[j].seq
+}
+
+func (q spikeQueue) Swap(i, j int) {
+    q[i], q[j] = q[j], q[i]
+}
+
+func (q *spikeQueue) Push(x interface{}) {
+    *q = append(*q, x.(*spikeEvent))
+}
+
+func (q *spikeQueue) Pop() interface{} {
+    old := *q
+    ev := old[len(old)-1]
+    old[len(old)-1] = nil
+    *q = old[:len(old)-1]
+    return ev
+}
+
+type scheduler struct {
+    Now float64
+    queue spikeQueue
+    seq int
+}
+
+func (s *scheduler) Schedule(t float64, n *Neuron, typ int, in ... float64) {
+    s.seq += 1
+    heap.Push(&s.queue, &spikeEvent{t, n, typ, in, s.seq})
+}
+
+func (s *scheduler) Next() *spikeEvent {
+    if len(s.queue) == 0 {
+        return nil
+    }
+    ev := heap.Pop(&s.queue).(*spikeEvent)
+    s.Now = ev.Time
+    return ev
+}
+
+func (s *scheduler) Pending() int {
+    return len(s.queue)
+}
+
+func (circuit *Circuit) Scheduler() *scheduler {
+    if circuit.scheduler == nil {
+        circuit.scheduler = &scheduler{}
+    }
+    return circuit.scheduler
+}
+
+// spawn runs f on its own goroutine and keeps track of it until it returns, so
+// that the circuit knows when its own activity has settled.
+func (circuit *Circuit) spawn(f func()) {
+    if atomic.LoadInt32(&circuit.halted) == 1 {
+        return
+    }
+    if circuit.TimeBudget > 0 && circuit.Now() - circuit.exposed > circuit.TimeBudget {
+        circuit.Halt(ErrTimeBudget)
+        return
+    }
+    circuit.active.Add(1)
+    go func() {
+        defer circuit.active.Done()
+        f()
+    }()
+}
+
+// Now is the simulated time in ms. The goroutine engine has no clock of its own,
+// so it reports the wall-clock time since the circuit was first exposed.
+func (circuit *Circuit) Now() float64 {
+    if circuit.Engine == enginetype.Goroutine {
+        if circuit.epoch.IsZero() {
+            return 0
+        }
+        return float64(time.Since(circuit.epoch)) / float64(time.Millisecond)
+    }
+    return circuit.Scheduler().Now
+}
+
+// Halt stops the current exposure; the first reason given is the one reported.
+func (circuit *Circuit) Halt(reason error) {
+    circuit.mu.Lock()
+    if circuit.stopped == nil {
+        circuit.stopped = reason
+    }
+    circuit.mu.Unlock()
+    atomic.StoreInt32(&circuit.halted, 1)
+}
+
+// spiked counts an activation against the circuit's spike budget and reports
+// whether there was room for it. Under the goroutine engine, activations may
+// already be under way when the budget runs out; they are dropped, so that no
+// more than SpikeBudget spikes happen on either engine.
+func (circuit *Circuit) spiked() bool {
+    for {
+        spikes := atomic.LoadInt64(&circuit.spikes)
+        if circuit.SpikeBudget > 0 && spikes >= int64(circuit.SpikeBudget) {
+            circuit.Halt(ErrSpikeBudget)
+            return false
+        }
+        if atomic.CompareAndSwapInt64(&circuit.spikes, spikes, spikes + 1) {
+            if circuit.SpikeBudget > 0 && spikes + 1 >= int64(circuit.SpikeBudget) {
+                circuit.Halt(ErrSpikeBudget)
+            }
+            return true
+        }
+    }
+}
+
+// Fire makes n activate latency ms from now, either on its own goroutine or
+// as an event. The event engine keeps at most one activation pending per neuron.
+func (circuit *Circuit) Fire(n *Neuron, latency float64) {
+    if circuit.Engine == enginetype.Goroutine {
+        circuit.spawn(func() {
+            if latency > 0 {
+                time.Sleep(time.Duration(latency * float64(time.Millisecond)))
+            }
+            n.Activate()
+        })
+        return
+    }
+    if n.pending {
+        return
+    }
+    n.pending = true
+    s := circuit.Scheduler()
+    s.Schedule(s.Now + latency, n, eventtype.Activate)
+}
+
+// Transmit delivers a spike across at to the neuron it synapses onto once the
+// terminal's conduction delay has passed.
+func (circuit *Circuit) Transmit(at *axonTerminal) {
+    if at.To == nil {
+        return
+    }
+    psp := at.Strength()
+    if circuit.Engine == enginetype.Goroutine {
+        n, delay := at.To.PartOf, at.Delay
+        circuit.spawn(func() {
+            if delay > 0 {
+                time.Sleep(time.Duration(delay * float64(time.Millisecond)))
+            }
+            if at.SynapseIsExcitatory {
+                n.Excite(psp)
+            } else {
+                n.Inhibit(psp)
+            }
+        })
+        return
+    }
+    s := circuit.Scheduler()
+    if at.SynapseIsExcitatory {
+        s.Schedule(s.Now + at.Delay, at.To.PartOf, eventtype.Excite, psp)
+    } else {
+        s.Schedule(s.Now + at.Delay, at.To.PartOf, eventtype.Inhibit, psp)
+    }
+}
+
+// Run processes queued events in order until the circuit falls silent, ctx is
+// done or a budget runs out. Events left over from a halted run are dropped.
+func (circuit *Circuit) Run(ctx context.Context) {
+    s := circuit.Scheduler()
+    for ev := s.Next(); ev != nil; ev = s.Next() {
+        if err := ctx.Err(); err != nil {
+            circuit.Halt(err)
+        } else if circuit.TimeBudget > 0 && ev.Time - circuit.exposed > circuit.TimeBudget {
+            circuit.Halt(ErrTimeBudget)
+        }
+        if atomic.LoadInt32(&circuit.halted) == 1 {
+            ev.Neuron.pending = false
+            for _, left := range s.queue {
+                left.Neuron.pending = false
+            }
+            s.queue = nil
+            return
+        }
+        
+        switch ev.Type {
+        case eventtype.Activate:
+            ev.Neuron.pending = false
+            ev.Neuron.Activate()
+        case eventtype.Excite:
+            ev.Neuron.Excite(ev.In...)
+        case eventtype.Inhibit:
+            ev.Neuron.Inhibit(ev.In...)
+        }
+    }
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+// The event engine makes training reproducible: two fresh circuits trained on
+// the same stimuli perceive the same and grow the same.
+func TestEventEngineIsDeterministic(t *testing.T) {
+    stimuli, labels := numbers(t)
+    a, b := &Circuit{}, &Circuit{}
+    a.Neurogenesis(256, 10)
+    b.Neurogenesis(256, 10)
+
+    wa, wb := train(t, a, stimuli, labels, 3), train(t, b, stimuli, labels, 3)
+    if !reflect.DeepEqual(wa, wb) {
+        t.Fatal("the same training perceived differently")
+    }
+    if len(a.Cluster) != len(b.Cluster) {
+        t.Fatalf("the same training grew %d and %d neurons", len(a.Cluster), len(b.Cluster))
+    }
+}
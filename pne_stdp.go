@@ -0,0 +1,78 @@
+package main
+
+import (
+    "math"
+)
+
+// STDP is spike-timing-dependent plasticity: a synapse whose presynaptic
+// neuron fired shortly before the postsynaptic one is potentiated, one whose
+// postsynaptic neuron fired first is depressed. The change decays
+// exponentially with the time between the two spikes.
+type STDP struct {
+    Potentiation float64
+    Depression float64
+    // time constants (simulated ms) of the potentiation and depression windows
+    PotentiationWindow float64
+    DepressionWindow float64
+    MinWeight float64
+    MaxWeight float64
+}
+
+// DefaultSTDP has slightly stronger depression than potentiation, so that
+// weights don't run away when spikes are uncorrelated.
+func DefaultSTDP() STDP {
+    return STDP{0.005, 0.00525, 20, 20, 0, 2 * defaultSynapticWeight}
+}
+
+func (p *STDP) adjust(at *axonTerminal, delta float64) {
+    at.Adjust(delta)
+    if at.Weight < p.MinWeight {
+        at.Weight = p.MinWeight
+    }
+    if at.Weight > p.MaxWeight {
+        at.Weight = p.MaxWeight
+    }
+}
+
+// Learn applies the circuit's STDP rule, eligibility traces and homeostasis
+// for a spike of n at the simulated time now and remembers the spike. Callers
+// hold the circuit's plasticity lock.
+func (circuit *Circuit) Learn(n *Neuron, now float64) {
+    if p := circuit.STDP; p != nil {
+        // n is presynaptic: postsynaptic spikes before now depress
+        for _, at := range n.Axon.Terminals {
+            if at.To == nil || !circuit.spikedThisExposure(at.To.PartOf) {
+                continue
+            }
+            dt := now - at.To.PartOf.LastSpike
+            p.adjust(at, -p.Depression * math.Exp(-dt / p.DepressionWindow))
+        }
+        // n is postsynaptic: presynaptic spikes before now potentiate
+        for _, at := range n.Afferents {
+            pre := at.From.From
+            if !circuit.spikedThisExposure(pre) {
+                continue
+            }
+            dt := now - pre.LastSpike
+            p.adjust(at, p.Potentiation * math.Exp(-dt / p.PotentiationWindow))
+        }
+    }
+    
+    if circuit.Reward != nil {
+        circuit.trace(n, now)
+    }
+    if circuit.Homeostasis != nil {
+        n.spikes += 1
+    }
+    
+    n.LastSpike = now
+    n.HasSpiked = true
+    n.exposure = circuit.exposures
+}
+
+// spikedThisExposure reports whether n has spiked during the current exposure.
+// Only such spikes are paired: the clock isn't advanced between exposures, so
+// the last spikes of one would otherwise pair with the first of the next.
+func (circuit *Circuit) spikedThisExposure(n *Neuron) bool {
+    return n.HasSpiked && n.exposure == circuit.exposures
+}
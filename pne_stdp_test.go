@@ -0,0 +1,81 @@
+package main
+
+import (
+    "testing"
+)
+
+// causal finds an excitatory synapse from a neuron of type from onto one of
+// type to that fired after it in the last exposure of c.
+func causal(c *Circuit, from int, to int) *axonTerminal {
+    for _, n := range c.Cluster {
+        if n.Type != from || !c.spikedThisExposure(n) {
+            continue
+        }
+        for _, at := range n.Axon.Terminals {
+            if at.To == nil {
+                continue
+            }
+            post := at.To.PartOf
+            if at.SynapseIsExcitatory && post.Type == to && c.spikedThisExposure(post) && post.LastSpike > n.LastSpike {
+                return at
+            }
+        }
+    }
+    return nil
+}
+
+// A sensory neuron that makes a deep neuron fire on every presentation of a
+// stimulus gets the synapse between them strengthened, not weakened.
+func TestSTDPPotentiatesCausalSynapse(t *testing.T) {
+    stimuli, _ := numbers(t)
+    stdp := DefaultSTDP()
+    c := &Circuit{STDP: &stdp}
+    c.Neurogenesis(256, 10)
+    stimulus := stimuli[0].GreyScale
+
+    c.ExposeTo(stimulus)
+    at := causal(c, neurontype.Sensory, neurontype.Deep)
+    if at == nil {
+        t.Fatal("no sensory neuron made a deep neuron fire")
+    }
+
+    weight := at.Weight
+    for i := 0; i < 35; i++ {
+        c.ExposeTo(stimulus)
+    }
+    if at.Weight <= weight {
+        t.Errorf("causal synapse went from %g to %g", weight, at.Weight)
+    }
+}
+
+// The clock isn't advanced between exposures, so a mechanical neuron that
+// fired at the very end of one exposure must not depress its afferents when the
+// deep neurons before it fire at the start of the next.
+func TestSTDPPairsSpikesOfOneExposure(t *testing.T) {
+    stimuli, _ := numbers(t)
+    stdp := DefaultSTDP()
+    c := &Circuit{STDP: &stdp}
+    c.Neurogenesis(256, 10)
+    stimulus := stimuli[0].GreyScale
+
+    var at *axonTerminal
+    for i := 0; i < 10 && at == nil; i++ {
+        c.ExposeTo(stimulus)
+        at = causal(c, neurontype.Deep, neurontype.Mechanical)
+    }
+    if at == nil {
+        t.Fatal("no deep neuron made a mechanical neuron fire")
+    }
+    if post := at.To.PartOf; post.LastSpike != c.Now() {
+        t.Fatalf("mechanical neuron fired at %g, not at the end of the exposure (%g)", post.LastSpike, c.Now())
+    }
+
+    // only depression is left, and within the next exposure the mechanical
+    // neuron fires after the deep one again
+    stdp.Potentiation = 0
+    weight := at.Weight
+    c.ExposeTo(stimulus)
+    if at.Weight < weight {
+        t.Errorf("causal synapse was depressed from %g to %g across exposures", weight, at.Weight)
+    }
+}
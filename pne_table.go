@@ -0,0 +1,252 @@
+package main
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+type ScalingType struct {
+    MinMax, ZScore int
+}
+
+var scalingtype = ScalingType{0, 1}
+
+var ErrNoLabel = errors.New("pne: table has no label column")
+
+// how many standard deviations either side of the mean z-score scaling maps
+// into the range of stimuli; values further out are clipped
+const zScoreRange = 3.0
+
+// ColumnScale is how a column's values were scaled into stimuli.
+type ColumnScale struct {
+    Column string
+    Min float64
+    Max float64
+    Mean float64
+    Std float64
+}
+
+// Scale maps v into 0..0.2, the range of image stimuli, clipping values
+// outside the column's range.
+func (s ColumnScale) Scale(v float64, scaling int) float64 {
+    var x float64
+    if scaling == scalingtype.ZScore {
+        if s.Std == 0 {
+            return 0.1
+        }
+        x = ((v - s.Mean) / s.Std + zScoreRange) / (2 * zScoreRange)
+    } else {
+        if s.Max == s.Min {
+            return 0
+        }
+        x = (v - s.Min) / (s.Max - s.Min)
+    }
+    return math.Max(0, math.Min(1, x)) * 0.2
+}
+
+// Table is a loaded table of stimuli. Labels names the outcome (Correct) of
+// every stimulus by the label it had in the table.
+type Table struct {
+    Stimuli []Stimulus
+    Labels *LabelSet
+    Scales []ColumnScale
+}
+
+// TableLoader reads stimuli from CSV or TSV: the values in Columns make up the
+// sensation and the value in Label the outcome. Columns are named by the
+// header if there is one, and numbered from 0 otherwise; no Columns means
+// every column but the label.
+//
+// If every label is a whole number, it is the outcome itself. Otherwise labels
+// are numbered in the order they first appear, after any Labels given.
+// Scaling is per column, by min/max or by z-score; pass a training set's
+// Scales and Labels on to its test set so that both are loaded the same way.
+type TableLoader struct {
+    // ',' unless LoadFile finds a .tsv file
+    Comma rune
+    Header bool
+    Columns []string
+    Label string
+    Scaling int
+    Scales []ColumnScale
+    Labels *LabelSet
+}
+
+// LoadFile loads the table at path, separated by tabs if it is a .tsv file.
+func (l TableLoader) LoadFile(path string) (*Table, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    
+    if l.Comma == 0 && strings.EqualFold(filepath.Ext(path), ".tsv") {
+        l.Comma = '\t'
+    }
+    t, err := l.Load(f)
+    if err != nil {
+        return nil, fmt.Errorf("pne: loading %s: %w", path, err)
+    }
+    return t, nil
+}
+
+func (l TableLoader) column(name string, header []string) (int, error) {
+    if l.Header {
+        for i, h := range header {
+            if strings.TrimSpace(h) == name {
+                return i, nil
+            }
+        }
+        return -1, fmt.Errorf("pne: table has no column %q", name)
+    }
+    i, err := strconv.Atoi(name)
+    if err != nil || i < 0 || i >= len(header) {
+        return -1, fmt.Errorf("pne: table has no column %q", name)
+    }
+    return i, nil
+}
+
+// Load reads a table from r.
+func (l TableLoader) Load(r io.Reader) (*Table, error) {
+    cr := csv.NewReader(r)
+    if l.Comma != 0 {
+        cr.Comma = l.Comma
+    }
+    cr.TrimLeadingSpace = true
+    rows, err := cr.ReadAll()
+    if err != nil {
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, ErrNoStimuli
+    }
+    
+    // columns are named by the header, or by their number
+    header := rows[0]
+    if l.Header {
+        rows = rows[1:]
+    } else {
+        header = make([]string, len(rows[0]))
+        for i := range header {
+            header[i] = strconv.Itoa(i)
+        }
+    }
+    if len(rows) == 0 {
+        return nil, ErrNoStimuli
+    }
+    if l.Label == "" {
+        return nil, ErrNoLabel
+    }
+    label, err := l.column(l.Label, header)
+    if err != nil {
+        return nil, err
+    }
+    var columns []int
+    if len(l.Columns) == 0 {
+        for i := range header {
+            if i != label {
+                columns = append(columns, i)
+            }
+        }
+    }
+    for _, name := range l.Columns {
+        i, err := l.column(name, header)
+        if err != nil {
+            return nil, err
+        }
+        columns = append(columns, i)
+    }
+    
+    values := make([][]float64, len(rows))
+    for n, row := range rows {
+        for _, c := range columns {
+            v, err := strconv.ParseFloat(strings.TrimSpace(row[c]), 64)
+            if err != nil {
+                return nil, fmt.Errorf("pne: row %d, column %q: %w", n + 1, header[c], err)
+            }
+            values[n] = append(values[n], v)
+        }
+    }
+    
+    t := &Table{Scales: l.Scales}
+    if t.Scales == nil {
+        for i, c := range columns {
+            t.Scales = append(t.Scales, columnScale(header[c], values, i))
+        }
+    }
+    if len(t.Scales) != len(columns) {
+        return nil, fmt.Errorf("pne: %d scales for %d columns", len(t.Scales), len(columns))
+    }
+    
+    outcomes, labels := tableOutcomes(rows, label, l.Labels)
+    t.Labels = labels
+    for n := range rows {
+        sensation := make([]float64, len(columns))
+        for i, v := range values[n] {
+            sensation[i] = t.Scales[i].Scale(v, l.Scaling)
+        }
+        t.Stimuli = append(t.Stimuli, Stimulus{sensation, outcomes[n]})
+    }
+    
+    return t, nil
+}
+
+func columnScale(name string, values [][]float64, i int) ColumnScale {
+    s := ColumnScale{Column: name, Min: math.Inf(1), Max: math.Inf(-1)}
+    for _, row := range values {
+        s.Min = math.Min(s.Min, row[i])
+        s.Max = math.Max(s.Max, row[i])
+        s.Mean += row[i]
+    }
+    s.Mean /= float64(len(values))
+    for _, row := range values {
+        s.Std += (row[i] - s.Mean) * (row[i] - s.Mean)
+    }
+    s.Std = math.Sqrt(s.Std / float64(len(values)))
+    return s
+}
+
+// tableOutcomes numbers the labels in column label, see TableLoader.
+func tableOutcomes(rows [][]string, label int, known *LabelSet) ([]int, *LabelSet) {
+    outcomes := make([]int, len(rows))
+    labels := &LabelSet{}
+    if known != nil {
+        labels.Names = append(labels.Names, known.Names...)
+    }
+    
+    numeric := labels.Len() == 0
+    for n, row := range rows {
+        o, err := strconv.Atoi(strings.TrimSpace(row[label]))
+        if err != nil || o < 0 {
+            numeric = false
+            break
+        }
+        outcomes[n] = o
+    }
+    if numeric {
+        for _, o := range outcomes {
+            for labels.Len() <= o {
+                labels.Names = append(labels.Names, strconv.Itoa(labels.Len()))
+            }
+        }
+        return outcomes, labels
+    }
+    
+    for n, row := range rows {
+        name := strings.TrimSpace(row[label])
+        o, ok := labels.Outcome(name)
+        if !ok {
+            o = labels.Len()
+            labels.Names = append(labels.Names, name)
+        }
+        outcomes[n] = o
+    }
+    return outcomes, labels
+}
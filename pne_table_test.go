@@ -0,0 +1,83 @@
+package main
+
+import (
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// Columns are picked by header or by number, scaled by min/max or z-score, and
+// a training set's Scales and Labels carry over to its test set.
+func TestTableLoader(t *testing.T) {
+    train := "width,species,height\n1,setosa,10\n3,virginica,30\n2,setosa,20\n"
+    byHeader, err := TableLoader{Header: true, Label: "species", Columns: []string{"height", "width"}}.Load(strings.NewReader(train))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []Stimulus{
+        {[]float64{0, 0}, 0},
+        {[]float64{0.2, 0.2}, 1},
+        {[]float64{0.1, 0.1}, 0},
+    }
+    if !reflect.DeepEqual(byHeader.Stimuli, want) {
+        t.Fatalf("min/max: got %v, want %v", byHeader.Stimuli, want)
+    }
+    if want := []string{"setosa", "virginica"}; !reflect.DeepEqual(byHeader.Labels.Names, want) {
+        t.Fatalf("labels %v, want %v", byHeader.Labels.Names, want)
+    }
+
+    rows := train[strings.Index(train, "\n") + 1:]
+    byNumber, err := TableLoader{Label: "1"}.Load(strings.NewReader(rows))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(byNumber.Stimuli, want) {
+        t.Fatalf("numbered columns: got %v, want %v", byNumber.Stimuli, want)
+    }
+
+    zscore, err := TableLoader{Label: "1", Scaling: scalingtype.ZScore}.Load(strings.NewReader(rows))
+    if err != nil {
+        t.Fatal(err)
+    }
+    // the middle row is the mean, the others are 1.22 standard deviations off
+    off := math.Sqrt(1.5) / zScoreRange * 0.1
+    for i, w := range []float64{0.1 - off, 0.1 + off, 0.1} {
+        for _, v := range zscore.Stimuli[i].Sensation {
+            if math.Abs(v - w) > 1e-12 {
+                t.Fatalf("z-score: row %d got %v, want %g", i, zscore.Stimuli[i].Sensation, w)
+            }
+        }
+    }
+
+    // a test set out of the training set's range, with a label it lacks
+    test := "2,virginica,40\n0,versicolor,20\n"
+    loaded, err := TableLoader{Label: "1", Scales: byNumber.Scales, Labels: byNumber.Labels}.Load(strings.NewReader(test))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want = []Stimulus{
+        {[]float64{0.1, 0.2}, 1},
+        {[]float64{0, 0.1}, 2},
+    }
+    if !reflect.DeepEqual(loaded.Stimuli, want) {
+        t.Fatalf("reused scales: got %v, want %v", loaded.Stimuli, want)
+    }
+    if want := []string{"setosa", "virginica", "versicolor"}; !reflect.DeepEqual(loaded.Labels.Names, want) {
+        t.Fatalf("reused labels: got %v, want %v", loaded.Labels.Names, want)
+    }
+    if !reflect.DeepEqual(byNumber.Labels.Names, []string{"setosa", "virginica"}) {
+        t.Fatal("loading the test set changed the training set's labels")
+    }
+}
+
+// Whole-number labels are outcomes as they are.
+func TestTableLoaderNumericLabels(t *testing.T) {
+    table, err := TableLoader{Label: "0"}.Load(strings.NewReader("2,1\n0,3\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if table.Stimuli[0].Correct != 2 || table.Stimuli[1].Correct != 0 || table.Labels.Len() != 3 {
+        t.Fatalf("got %v with labels %v", table.Stimuli, table.Labels.Names)
+    }
+}